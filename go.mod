@@ -0,0 +1,5 @@
+module github.com/grubbymits/noisey-world
+
+go 1.16
+
+require github.com/ojrac/opensimplex-go v1.0.2
@@ -0,0 +1,29 @@
+package main
+
+import (
+  "flag"
+  "fmt"
+  "os"
+
+  "github.com/grubbymits/noisey-world/noisey"
+)
+
+func main() {
+  config, err := noisey.ParseConfig(os.Args[1:])
+  if err == flag.ErrHelp {
+    return
+  } else if err != nil {
+    fmt.Println(err)
+    os.Exit(1)
+  }
+  width := config.Width
+  height := config.Height
+  threads := config.Threads
+
+  fmt.Println("width, height, threads")
+  fmt.Println(width, ",", height, ",", threads)
+  if err := noisey.GenerateMap(config); err != nil {
+    fmt.Println(err)
+    os.Exit(1)
+  }
+}
@@ -0,0 +1,118 @@
+package noisey
+
+import (
+  "image"
+  "image/color"
+  "image/gif"
+  "os"
+  "sort"
+)
+
+// Ways of saving the animated tiles of the detailed map.
+const (
+  // Only the first frame, as a still map.
+  NO_ANIMATION = iota
+  // Every frame as its own png.
+  FRAME_ANIMATION
+  // The still map along with an animated gif of every frame.
+  GIF_ANIMATION
+  NUM_ANIMATIONS
+)
+
+var ANIMATION_NAMES = [NUM_ANIMATIONS]string {
+  "none",
+  "frames",
+  "gif",
+}
+
+// The most common colours in the image, up to 'size' of them.
+func popularPalette(img *image.RGBA, size int) color.Palette {
+  counts := make(map[color.RGBA]int)
+  bounds := img.Bounds()
+  for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+    for x := bounds.Min.X; x < bounds.Max.X; x++ {
+      counts[img.RGBAAt(x, y)]++
+    }
+  }
+  colours := make([]color.RGBA, 0, len(counts))
+  for c := range counts {
+    colours = append(colours, c)
+  }
+  sort.Slice(colours, func(i, j int) bool {
+    a, b := colours[i], colours[j]
+    if counts[a] != counts[b] {
+      return counts[a] > counts[b]
+    }
+    return a.R < b.R || (a.R == b.R && (a.G < b.G ||
+                                        (a.G == b.G && a.B < b.B)))
+  })
+  if len(colours) > size {
+    colours = colours[:size]
+  }
+  p := make(color.Palette, len(colours))
+  for i, c := range colours {
+    p[i] = c
+  }
+  return p
+}
+
+// Map each pixel to the closest colour in the palette, without dithering, so
+// that pixel art stays crisp. Colours are looked up once and kept in 'cache'.
+func quantise(img *image.RGBA, p color.Palette,
+              cache map[color.RGBA]uint8) *image.Paletted {
+  bounds := img.Bounds()
+  paletted := image.NewPaletted(bounds, p)
+  for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+    for x := bounds.Min.X; x < bounds.Max.X; x++ {
+      c := img.RGBAAt(x, y)
+      idx, ok := cache[c]
+      if !ok {
+        idx = uint8(p.Index(c))
+        cache[c] = idx
+      }
+      paletted.SetColorIndex(x, y, idx)
+    }
+  }
+  return paletted
+}
+
+// Draw every frame of the whole map, scaled up by 'scale', into an animated
+// gif. The frames share a palette of the colours used most in the first one.
+// Unlike the png, the whole map is held in memory for each frame.
+func (render *MapRenderer) SaveGIF(w *World, numCPUs, scale int,
+                                   filename string) error {
+  anim := new(gif.GIF)
+  var p color.Palette
+  cache := make(map[color.RGBA]uint8)
+  for frame := 0; frame < render.frames; frame++ {
+    render.frame = frame
+    img, err := render.DrawArea(w, image.Rect(0, 0, w.width, w.height),
+                                numCPUs)
+    if err != nil {
+      return err
+    }
+    scaled := img
+    if scale != 1 {
+      scaled = Upscale(img, scale).(*image.RGBA)
+    }
+    if p == nil {
+      p = popularPalette(scaled, 256)
+    }
+    anim.Image = append(anim.Image, quantise(scaled, p, cache))
+    anim.Delay = append(anim.Delay, render.frameDelay / 10)
+  }
+  render.frame = 0
+
+  imgFile, err := os.Create(filename)
+  if err != nil {
+    return newError(ErrEncode, filename, err)
+  }
+  if err := gif.EncodeAll(imgFile, anim); err != nil {
+    imgFile.Close()
+    return newError(ErrEncode, filename, err)
+  }
+  if err := imgFile.Close(); err != nil {
+    return newError(ErrEncode, filename, err)
+  }
+  return nil
+}
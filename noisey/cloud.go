@@ -1,21 +1,22 @@
-package main
+package noisey
 
 type Cloud struct {
   moisture float64
   direction uint
+  distance int
   loc *Location
-  world *World
+  sim *MoistureSim
 }
 
 const RAIN = 0.5
 
 func CreateCloud(moisture float64, direction uint, loc *Location,
-                 world *World) *Cloud {
+                 sim *MoistureSim) *Cloud {
   c := new(Cloud)
   c.moisture = moisture
   c.direction = direction
   c.loc = loc
-  c.world = world
+  c.sim = sim
   return c
 }
 
@@ -27,12 +28,13 @@ func (c *Cloud) update() bool {
     return true
   }
 
-  nextLoc := c.world.getDirectedLocation(c.loc, c.direction)
+  nextLoc := c.sim.world.getDirectedLocation(c.loc, c.direction)
 
-  // Reached the end of map.
-  if nextLoc == nil {
+  // Reached the end of map, or gone all the way around a wrapping one.
+  if nextLoc == nil || c.distance >= c.sim.maxDistance {
     return true
   }
+  c.distance++
 
   // Don't start 'raining' until the cloud gets to land.
   if nextLoc.height < RAIN_LEVEL {
@@ -45,21 +47,27 @@ func (c *Cloud) update() bool {
   total := RAIN + (boost * multiplier)
 
   if c.moisture < total {
-    nextLoc.moisture += c.moisture
+    c.sim.rain(nextLoc, c.moisture)
     c.moisture = 0
   } else {
     // Dissipate some moisture to the land.
-    nextLoc.moisture += total
+    c.sim.rain(nextLoc, total)
     c.moisture -= total
   }
 
   // Treat terraces as obsticles that will cause the cloud to split into
   // multiple clouds, with a maximum of two new clouds, each taking some of the
-  // moisture. Each new cloud will travel in a different direction.
+  // moisture. Each new cloud will travel in a different direction. If the
+  // simulation refuses the split, the moisture stays with this cloud.
   if nextLoc.terrace > c.loc.terrace {
-    c.world.addCloud(c, (c.direction + 1) % MAX_DIR)
-    c.world.addCloud(c, (c.direction - 1) % MAX_DIR)
-    c.moisture /= 3
+    share := c.moisture / 3
+    dirs := [2]uint { (c.direction + 1) % MAX_DIR,
+                      (c.direction + MAX_DIR - 1) % MAX_DIR }
+    for _, dir := range dirs {
+      if c.sim.split(c, dir, share) {
+        c.moisture -= share
+      }
+    }
   }
 
   c.loc = nextLoc
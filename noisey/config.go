@@ -0,0 +1,359 @@
+package noisey
+
+import (
+  "errors"
+  "flag"
+  "math/rand"
+  "strconv"
+  "time"
+)
+
+// All the parameters which control how a world is generated and rendered.
+type Config struct {
+  Width, Height int
+  WrapX, WrapY bool
+  Threads int
+  WindDir uint
+
+  HeightNoise NoiseConfig
+  HeightBaseline, EdgeUp, EdgeDown, Falloff float64
+  Shape string
+  Islands int
+  OpenEdge uint
+  Mask string
+  Heightmap string
+  HeightmapMin, HeightmapMax, HeightmapMix float64
+  TreeNoise, PlantNoise, RockNoise NoiseConfig
+  Placement int
+  Spacing float64
+  LargeFeatures float64
+
+  Water, Saturate float64
+  MaxClouds int
+  MergeClouds bool
+  CloudStats bool
+
+  LatNorth, LatSouth float64
+  LapseRate float64
+  TempNoise, TempFreq float64
+
+  SFreq, SoilSlope, SoilHeight float64
+
+  Seasons []int
+  Tileset string
+  Resources string
+  OutDir, Name string
+  Scale, OverworldScale int
+  Band, Split int
+  Animate int
+  Iso bool
+  Hillshade, SunAzimuth, SunElevation, Occlusion float64
+  Poster bool
+  PosterScale int
+  PosterLabels bool
+  Layers bool
+  Timelapse bool
+  TimelapseClouds int
+
+  Seeds Seeds
+}
+
+// Parse the command line arguments, not including the program name, into a
+// config. Any seed which isn't given is chosen at random.
+func ParseConfig(args []string) (*Config, error) {
+  flags := flag.NewFlagSet("noisey-world", flag.ContinueOnError)
+  // 64 x 48 = 1024 x 768
+  // 128 x 96 = 2048 x 1546
+  // 192 x 144 = 3072 x 2304
+  // 192 x 192 = 3072 x 3072
+  width := flags.Int("width", 192, "map width")
+  height := flags.Int("height", 144, "map height")
+  wrap := flags.String("wrap", "none",
+                      "edges which wrap around: none, x for east to west " +
+                      "or xy for both axes")
+  heightNoise := noiseFlags(flags, "h", "height", 1.6, 0.75)
+  bias := flags.Float64("bias", 0.0, "height bias")
+  edgeUp := flags.Float64("raise-edge", 0.07, "raise edges")
+  edgeDown := flags.Float64("lower-edge", 1.41, "lower edges")
+  falloff := flags.Float64("falloff", 1.5, "falloff rate")
+  shape := flags.String("shape", "square",
+                       "island shape: none,square,radial,elliptical," +
+                       "archipelago,continent or mask")
+  islands := flags.Int("islands", 5, "number of islands in an archipelago")
+  openEdge := flags.String("open-edge", "s",
+                          "edge a continent continues off: n,e,s,w")
+  mask := flags.String("mask", "",
+                      "grayscale png defining the land for the mask shape")
+
+  water := flags.Float64("water", 100, "water")
+  saturate := flags.Float64("saturate", 30, "water saturation level")
+  direction := flags.String("wind", "n", "wind direction")
+  maxClouds := flags.Int("max-clouds", 0,
+                        "maximum number of clouds alive at once, 0 for no limit")
+  mergeClouds := flags.Bool("merge-clouds", true,
+                           "merge clouds which meet travelling the same way")
+  cloudStats := flags.Bool("cloud-stats", false,
+                          "print statistics for each moisture step")
+  latNorth := flags.Float64("lat-north", 52, "latitude of the northern edge")
+  latSouth := flags.Float64("lat-south", 50, "latitude of the southern edge")
+  lapseRate := flags.Float64("lapse-rate", 6.5,
+                            "temperature drop, in degrees, per unit of height")
+  tempNoise := flags.Float64("temp-noise", 0,
+                            "maximum temperature variation from noise")
+  tempFreq := flags.Float64("temp-freq", 4, "temperature noise frequency")
+  sFreq := flags.Float64("sFreq", 6, "soil depth noise frequency")
+  soilSlope := flags.Float64("soil-slope", 10,
+                            "soil depth lost per unit of slope")
+  soilHeight := flags.Float64("soil-height", 1,
+                             "soil depth lost per unit of height above the lowlands")
+  heightmap := flags.String("heightmap", "",
+                           "8 or 16-bit grayscale png to import heights from")
+  heightmapMin := flags.Float64("heightmap-min", -1,
+                               "height of black in the height map")
+  heightmapMax := flags.Float64("heightmap-max", 1.5,
+                               "height of white in the height map")
+  heightmapMix := flags.Float64("heightmap-mix", 0,
+                               "proportion of noise mixed into the height map")
+  treeNoise := noiseFlags(flags, "t", "tree", 200, 1)
+  plantNoise := noiseFlags(flags, "p", "plant", 200, 1)
+  rockNoise := noiseFlags(flags, "r", "rock", 200, 1)
+  placement := flags.String("placement", "region",
+                           "feature placement: region for the highest noise " +
+                           "in each region or poisson for evenly spaced")
+  spacing := flags.Float64("spacing", 0.8,
+                          "minimum distance between poisson placed features, " +
+                          "relative to their average distance")
+  largeFeatures := flags.Float64("large-features", 0,
+                                "chance of a tree or rock being drawn large, " +
+                                "where there is room for it")
+  outDir := flags.String("outdir", ".", "directory to write the results to")
+  name := flags.String("name", "h{hseed}-t{tseed}-p{pseed}-r{rseed}",
+                      "prefix shared by the files of a run, with " +
+                      "placeholders {hseed}, {tseed}, {pseed}, {rseed}, " +
+                      "{teseed} and {sseed} for the seeds, {width}, " +
+                      "{height}, {time} and {hash} for a hash of the " +
+                      "settings which change the world")
+  threads := flags.Int("threads", 1, "number of cores to use")
+  layers := flags.Bool("layers", false, "export the data layers as images")
+  timelapse := flags.Bool("timelapse", false,
+                         "save an animated gif of the overworld after each " +
+                         "generation stage")
+  timelapseClouds := flags.Int("timelapse-clouds", 0,
+                              "also add a timelapse frame of the moisture " +
+                              "every this many cloud steps, 0 for none")
+  seasons := flags.String("season", "summer",
+                         "seasons to render: spring,summer,autumn,winter or all")
+  tileset := flags.String("tileset", "tileset.json",
+                         "tileset description to draw with")
+  resources := flags.String("resources", "",
+                           "directory of resources which replace the " +
+                           "built-in ones with the same name")
+  scale := flags.Int("scale", 1,
+                    "whole number to scale the detailed map up by")
+  overworldScale := flags.Int("overworld-scale", 1,
+                             "pixels per tile in the overworld image")
+  band := flags.Int("band", 32,
+                   "rows of tiles drawn at a time, which bounds the memory " +
+                   "used by the detailed map")
+  split := flags.Int("split", 0,
+                    "save the detailed map as separate files of this many " +
+                    "tiles square, rather than one file")
+  hillshade := flags.Float64("hillshade", 0,
+                            "strength of the shading of slopes by the sun, " +
+                            "0 for none")
+  sunAzimuth := flags.Float64("sun-azimuth", 315,
+                             "direction of the sun, in degrees clockwise " +
+                             "from north")
+  sunElevation := flags.Float64("sun-elevation", 45,
+                               "height of the sun, in degrees above the " +
+                               "horizon")
+  occlusion := flags.Float64("occlusion", 0,
+                            "strength of the darkening at the foot of walls " +
+                            "and under trees, 0 for none")
+  poster := flags.Bool("poster", false,
+                       "also draw the overworld as a poster with a legend, " +
+                       "scale bar, compass and the seeds")
+  posterScale := flags.Int("poster-scale", 4, "pixels per tile in the poster")
+  posterLabels := flags.Bool("poster-labels", false,
+                            "name the islands, peaks and rivers on the poster")
+  iso := flags.Bool("iso", false,
+                    "also draw each detailed map from an isometric camera")
+  animate := flags.String("animate", "none",
+                         "animated tiles in the detailed map: none, frames " +
+                         "for a png of each frame or gif to add an animated gif")
+
+  seeds := [...]*string {
+    flags.String("hSeed", "", "height seed, in hex, or random if not given"),
+    flags.String("tSeed", "", "tree seed, in hex, or random if not given"),
+    flags.String("pSeed", "", "plant seed, in hex, or random if not given"),
+    flags.String("rSeed", "", "rock seed, in hex, or random if not given"),
+    flags.String("teSeed", "",
+                 "temperature seed, in hex, or random if not given"),
+    flags.String("sSeed", "", "soil seed, in hex, or random if not given"),
+  }
+
+  if err := flags.Parse(args); err != nil {
+    if err == flag.ErrHelp {
+      return nil, err
+    }
+    return nil, newError(ErrBadParameter, "command line", err)
+  }
+
+  config := new(Config)
+  config.Width = *width
+  config.Height = *height
+  config.Threads = *threads
+  config.HeightNoise = *heightNoise
+  config.HeightBaseline = *bias
+  config.EdgeUp = *edgeUp
+  config.EdgeDown = *edgeDown
+  config.Falloff = *falloff
+  config.Shape = *shape
+  config.Islands = *islands
+  config.Mask = *mask
+  config.Heightmap = *heightmap
+  config.HeightmapMin = *heightmapMin
+  config.HeightmapMax = *heightmapMax
+  config.HeightmapMix = *heightmapMix
+  config.TreeNoise = *treeNoise
+  config.PlantNoise = *plantNoise
+  config.RockNoise = *rockNoise
+  config.Spacing = *spacing
+  config.LargeFeatures = *largeFeatures
+  config.Water = *water
+  config.Saturate = *saturate
+  config.MaxClouds = *maxClouds
+  config.MergeClouds = *mergeClouds
+  config.CloudStats = *cloudStats
+  config.LatNorth = *latNorth
+  config.LatSouth = *latSouth
+  config.LapseRate = *lapseRate
+  config.TempNoise = *tempNoise
+  config.TempFreq = *tempFreq
+  config.SFreq = *sFreq
+  config.SoilSlope = *soilSlope
+  config.SoilHeight = *soilHeight
+  config.Tileset = *tileset
+  config.Resources = *resources
+  config.OutDir = *outDir
+  config.Name = *name
+  config.Scale = *scale
+  config.OverworldScale = *overworldScale
+  config.Band = *band
+  config.Split = *split
+  config.Layers = *layers
+  config.Iso = *iso
+  config.Hillshade = *hillshade
+  config.SunAzimuth = *sunAzimuth
+  config.SunElevation = *sunElevation
+  config.Occlusion = *occlusion
+  config.Poster = *poster
+  config.PosterScale = *posterScale
+  config.PosterLabels = *posterLabels
+  config.Timelapse = *timelapse
+  config.TimelapseClouds = *timelapseClouds
+
+  var err error
+  rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+  values := [...]*int64 {
+    &config.Seeds.Height, &config.Seeds.Tree, &config.Seeds.Plant,
+    &config.Seeds.Rock, &config.Seeds.Temperature, &config.Seeds.Soil,
+  }
+  for i, seed := range seeds {
+    if *seed == "" {
+      *values[i] = rng.Int63()
+    } else if *values[i], err = strconv.ParseInt(*seed, 16, 64); err != nil {
+      return nil, newError(ErrBadParameter,
+                           "Invalid seed '" + *seed + "', give it in hex", nil)
+    }
+  }
+
+  if config.Seasons, err = ParseSeasons(*seasons); err != nil {
+    return nil, err
+  }
+
+  config.Placement = -1
+  for i, name := range PLACEMENT_NAMES {
+    if name == *placement {
+      config.Placement = i
+    }
+  }
+  if config.Placement == -1 {
+    return nil, newError(ErrBadParameter,
+                         "Invalid placement, choose: region or poisson", nil)
+  }
+
+  config.Animate = -1
+  for i, name := range ANIMATION_NAMES {
+    if name == *animate {
+      config.Animate = i
+    }
+  }
+  if config.Animate == -1 {
+    return nil, newError(ErrBadParameter,
+                         "Invalid animation, choose: none, frames or gif", nil)
+  }
+
+  switch *wrap {
+  case "none":
+  case "x":
+    config.WrapX = true
+  case "xy":
+    config.WrapX = true
+    config.WrapY = true
+  default:
+    return nil, newError(ErrBadParameter,
+                         "Invalid wrap, choose: none,x or xy", nil)
+  }
+
+  if config.WindDir, err = parseDirection(*direction); err != nil {
+    return nil, newError(ErrBadParameter,
+                         "Invalid wind direction, choose: n,e,s,w", nil)
+  }
+  if config.OpenEdge, err = parseDirection(*openEdge); err != nil {
+    return nil, newError(ErrBadParameter,
+                         "Invalid open edge, choose: n,e,s,w", nil)
+  }
+  return config, nil
+}
+
+// Register the flags for one fractal noise layer, each prefixed by 'prefix',
+// such as -hFreq and -hOctaves for the height.
+func noiseFlags(flags *flag.FlagSet, prefix, name string,
+                freq, gain float64) *NoiseConfig {
+  noise := new(NoiseConfig)
+  flags.StringVar(&noise.Source, prefix + "Source", "simplex",
+                 name + " noise source: simplex,perlin,value or worley")
+  flags.StringVar(&noise.Mode, prefix + "Mode", "fbm",
+                 name + " noise mode: fbm,billow,ridged or warp")
+  flags.IntVar(&noise.Octaves, prefix + "Octaves", 4,
+              name + " noise octaves")
+  flags.Float64Var(&noise.Freq, prefix + "Freq", freq,
+                  name + " noise frequency")
+  flags.Float64Var(&noise.Gain, prefix + "Gain", gain,
+                  name + " noise weight of the first octave, in place of " +
+                  "1, while the later octaves are weighted by the " +
+                  "persistence, its square and so on")
+  flags.Float64Var(&noise.Lacunarity, prefix + "Lacunarity", 2,
+                  name + " noise frequency multiplier between octaves")
+  flags.Float64Var(&noise.Persistence, prefix + "Persistence", 0.5,
+                  name + " noise amplitude multiplier between octaves")
+  flags.Float64Var(&noise.Warp, prefix + "Warp", 0.1,
+                  name + " noise displacement, as a fraction of the map, " +
+                  "in warp mode")
+  return noise
+}
+
+func parseDirection(dir string) (uint, error) {
+  switch dir {
+  case "n":
+    return NORTH, nil
+  case "e":
+    return EAST, nil
+  case "s":
+    return SOUTH, nil
+  case "w":
+    return WEST, nil
+  }
+  return NORTH, errors.New("invalid direction")
+}
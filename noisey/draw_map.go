@@ -0,0 +1,775 @@
+package noisey
+
+import (
+  "fmt"
+  "image"
+  "image/color"
+  "image/draw"
+  "image/png"
+  "math/rand"
+  "os"
+  "sort"
+  "strconv"
+  "sync"
+)
+
+/*
+const LIGHT_PINE = TREES_END * 2
+const DARK_PINE = LIGHT_PINE + 1
+const RIVER_BANK_COLUMN = 10
+*/
+
+// The sprites of the floor manifest for each kind of ground, named after the
+// ground, which is tagged with the biomes it covers, with these suffixes.
+const (
+  WALL_SUFFIX = "_wall"
+  BLEND_SUFFIX = "_blend"
+)
+
+// The edges of water, by the river bank feature which they're drawn for.
+var WATER_EDGE_SUFFIXES = map[uint]string {
+  TOP_LEFT_RIVER_FEATURE: "_top_left_water",
+  TOP_RIVER_FEATURE: "_top_water",
+  TOP_RIGHT_RIVER_FEATURE: "_top_right_water",
+  LEFT_RIVER_FEATURE: "_left_water",
+  RIGHT_RIVER_FEATURE: "_right_water",
+  BOTTOM_LEFT_RIVER_FEATURE: "_bottom_left_water",
+  BOTTOM_RIVER_FEATURE: "_bottom_water",
+  BOTTOM_RIGHT_RIVER_FEATURE: "_bottom_right_water",
+}
+
+// The sprites in the shadow manifest.
+const (
+  LEFT_VERTICAL_SHADOW = "left_vertical"
+  HORIZONTAL_SHADOW = "horizontal"
+  RIGHT_VERTICAL_SHADOW = "right_vertical"
+  BOTTOM_LEFT_SHADOW = "bottom_left"
+  BOTTOM_RIGHT_SHADOW = "bottom_right"
+)
+
+// The paths drawn over each kind of ground, by name in the path manifest.
+const (
+  GRASS_PATH = iota
+  SAND_PATH
+  NUM_PATH_SPRITES
+)
+
+var PATH_SPRITES = [NUM_PATH_SPRITES]string {
+  "grass_path",
+  "sand_path",
+}
+
+// The sprite in the water manifest drawn for open water.
+const WATER_SPRITE = "water"
+
+// A feature sprite waiting to be drawn, once all the ground has been.
+type SpriteDraw struct {
+  x, y int
+  sheet *SpriteSheet
+  idx int
+  anchor image.Point
+}
+
+type MapRenderer struct {
+  mapWidth, mapHeight, tileWidth, tileHeight, tileColumns, tileRows int
+  floorSheet *SpriteSheet
+  shadowSheet *SpriteSheet
+  treeSheet *SpriteSheet
+  plantSheet *SpriteSheet
+  rockSheet *SpriteSheet
+  pathSheet *SpriteSheet
+  largeSheet *SpriteSheet
+  // Animated water, if the tileset has it, which shows through the pixels of
+  // the river bank tiles set in their masks.
+  waterSheet *SpriteSheet
+  waterMasks map[int]*image.Alpha
+  // Hillshading and ambient occlusion applied to the ground, if any.
+  lighting *Lighting
+  // The frame being drawn, of the number needed for every animated sprite to
+  // loop, each of which is shown for frameDelay milliseconds.
+  frame, frames, frameDelay int
+  // The ground of the floor drawn for each biome.
+  grounds [BIOMES]string
+  // The sprite names to choose from in each biome.
+  trees, plants, rocks [BIOMES][]string
+  // The tiles being drawn.
+  area image.Rectangle
+  // How many tiles the largest sprites reach to the left and up, in Min,
+  // and to the right and down, in Max, of the tile they're drawn on.
+  reach image.Rectangle
+  mapImg *image.RGBA
+  sprites []SpriteDraw
+  // The first error from drawing the tiles, if any, which is also guarded
+  // by spritesLock.
+  err error
+  spritesLock sync.Mutex
+  season int
+  seed int64
+}
+
+// Create a renderer for a map of width x height tiles in the given season.
+// Renderers created with the same seed make the same random choices, so the
+// seasons of one world line up.
+func CreateMapRenderer(width, height, season int, seed int64,
+                       tileset *Tileset) (*MapRenderer, error) {
+  render := new(MapRenderer)
+  render.tileWidth = tileset.TileWidth
+  render.tileHeight = tileset.TileHeight
+  render.mapWidth = width * render.tileWidth
+  render.mapHeight = height * render.tileHeight
+  render.season = season
+  render.seed = seed
+  var err error
+  sheets := []struct {
+    sheet **SpriteSheet
+    manifest string
+  } {
+    { &render.floorSheet, tileset.Floor },
+    { &render.shadowSheet, tileset.Shadows },
+    { &render.treeSheet, tileset.Trees },
+    { &render.rockSheet, tileset.Rocks },
+    { &render.plantSheet, tileset.Plants },
+    { &render.pathSheet, tileset.Paths },
+    { &render.largeSheet, tileset.LargeFeatures },
+  }
+  for _, s := range sheets {
+    if *s.sheet, err = tileset.LoadSheet(s.manifest); err != nil {
+      return nil, err
+    }
+  }
+  if render.grounds, err = floorGrounds(render.floorSheet.manifest);
+     err != nil {
+    return nil, err
+  }
+  // Leave the water as it is and tint all the ground tiles.
+  water := make(map[color.NRGBA]bool)
+  for _, biome := range []int{ OCEAN, RIVER } {
+    for _, idx := range render.floorSheet.names[render.grounds[biome]] {
+      for c := range render.floorSheet.Palette(idx) {
+        water[c] = true
+      }
+    }
+  }
+  render.floorSheet = render.floorSheet.Tint(SEASON_TINTS[season], water)
+  if err := render.shadowSheet.manifest.Require(LEFT_VERTICAL_SHADOW,
+                                                HORIZONTAL_SHADOW,
+                                                RIGHT_VERTICAL_SHADOW,
+                                                BOTTOM_LEFT_SHADOW,
+                                                BOTTOM_RIGHT_SHADOW);
+     err != nil {
+    return nil, err
+  }
+  if err := render.pathSheet.manifest.Require(PATH_SPRITES[:]...);
+     err != nil {
+    return nil, err
+  }
+  render.trees = render.treeSheet.manifest.BiomeSprites(nil)
+  render.rocks = render.rockSheet.manifest.BiomeSprites(nil)
+  render.plants = render.plantSheet.manifest.BiomeSprites(nil)
+  for _, sheet := range []*SpriteSheet{ render.treeSheet, render.rockSheet,
+                                        render.plantSheet, render.largeSheet } {
+    for _, entry := range sheet.manifest.Sprites {
+      render.extendReach(entry.TileSize(), entry.AnchorTile())
+    }
+  }
+  animated := []*SpriteSheet{ render.treeSheet, render.rockSheet,
+                              render.plantSheet, render.largeSheet }
+  if tileset.Water != "" {
+    if render.waterSheet, err = tileset.LoadSheet(tileset.Water); err != nil {
+      return nil, err
+    }
+    if err := render.waterSheet.manifest.Require(WATER_SPRITE); err != nil {
+      return nil, err
+    }
+    render.findWaterMasks()
+    animated = append(animated, render.waterSheet)
+  }
+  render.frames = 1
+  for _, sheet := range animated {
+    frames, delay := sheet.manifest.Animation()
+    render.frames = render.frames / gcd(render.frames, frames) * frames
+    if delay != 0 && (render.frameDelay == 0 || delay < render.frameDelay) {
+      render.frameDelay = delay
+    }
+  }
+  return render, nil
+}
+
+// The ground of each biome, which is the sprite in the floor manifest tagged
+// with it. Every biome needs exactly one, along with its walls, blend and
+// edges of water.
+func floorGrounds(m *SpriteManifest) ([BIOMES]string, error) {
+  grounds := m.BiomeSprites(nil)
+  var names [BIOMES]string
+  for biome, tagged := range grounds {
+    if len(tagged) != 1 {
+      return names, newError(ErrBadResource, m.Image + ": the " +
+                             BIOME_NAMES[biome] + " biome needs to be " +
+                             "tagged on exactly one ground", nil)
+    }
+    ground := tagged[0]
+    if err := m.Require(ground + WALL_SUFFIX, ground + BLEND_SUFFIX);
+       err != nil {
+      return names, err
+    }
+    for _, suffix := range WATER_EDGE_SUFFIXES {
+      if err := m.Require(ground + suffix); err != nil {
+        return names, err
+      }
+    }
+    names[biome] = ground
+  }
+  return names, nil
+}
+
+// Find the pixels of each river bank tile which are the main colour of the
+// water, so the animated water can show through them.
+func (render *MapRenderer) findWaterMasks() {
+  water := render.waterSheet.names[WATER_SPRITE][0]
+  counts := make(map[color.NRGBA]int)
+  srcR := render.waterSheet.sprites[water]
+  for y := srcR.Min.Y; y < srcR.Max.Y; y++ {
+    for x := srcR.Min.X; x < srcR.Max.X; x++ {
+      c := render.waterSheet.spritesheet.At(x, y)
+      counts[color.NRGBAModel.Convert(c).(color.NRGBA)]++
+    }
+  }
+  var main color.NRGBA
+  for c, n := range counts {
+    if n > counts[main] {
+      main = c
+    }
+  }
+
+  sheet := render.floorSheet
+  render.waterMasks = make(map[int]*image.Alpha)
+  for _, ground := range render.grounds {
+    for _, suffix := range WATER_EDGE_SUFFIXES {
+      idx := sheet.names[ground + suffix][0]
+      srcR := sheet.sprites[idx]
+      mask := image.NewAlpha(image.Rect(0, 0, srcR.Dx(), srcR.Dy()))
+      for y := srcR.Min.Y; y < srcR.Max.Y; y++ {
+        for x := srcR.Min.X; x < srcR.Max.X; x++ {
+          c := color.NRGBAModel.Convert(sheet.spritesheet.At(x, y))
+          if c.(color.NRGBA) == main {
+            mask.SetAlpha(x - srcR.Min.X, y - srcR.Min.Y, color.Alpha{ 0xff })
+          }
+        }
+      }
+      render.waterMasks[idx] = mask
+    }
+  }
+}
+
+func (render *MapRenderer) extendReach(size, anchor image.Point) {
+  reach := &render.reach
+  if anchor.X > reach.Min.X {
+    reach.Min.X = anchor.X
+  }
+  if anchor.Y > reach.Min.Y {
+    reach.Min.Y = anchor.Y
+  }
+  if size.X - 1 - anchor.X > reach.Max.X {
+    reach.Max.X = size.X - 1 - anchor.X
+  }
+  if size.Y - 1 - anchor.Y > reach.Max.Y {
+    reach.Max.Y = size.Y - 1 - anchor.Y
+  }
+}
+
+func (render *MapRenderer) DrawRiverBankFeature(x, y int, feat uint,
+                                                biome uint8) error {
+  suffix, ok := WATER_EDGE_SUFFIXES[feat]
+  if !ok {
+    return newError(ErrBadResource, "no water edge tile for the river " +
+                    "bank at " + strconv.Itoa(x) + "," + strconv.Itoa(y), nil)
+  }
+  idx := render.floorSheet.names[render.grounds[biome] + suffix][0]
+  render.floorSheet.DrawFeature(x, y, idx, render.mapImg)
+  // The first frame is the tile as it is in the sheet, while the later ones
+  // pick up the movement of the open water.
+  if render.waterSheet != nil && render.frame != 0 {
+    water := render.waterSheet.names[WATER_SPRITE][0]
+    render.waterSheet.DrawMasked(x, y,
+                                 render.waterSheet.Frame(water, render.frame),
+                                 render.waterMasks[idx], render.mapImg)
+  }
+  return nil
+}
+
+// Draw the ground features of a location and queue its trees, rocks and
+// plants in 'sprites', so they can be drawn in order once the ground is
+// finished.
+func (render *MapRenderer) DrawFeatures(loc *Location, biome uint8, x, y int,
+                                        rng *rand.Rand,
+                                        sprites *[]SpriteDraw) {
+  if loc.isWall {
+    wall := render.floorSheet.Variant(render.grounds[biome] + WALL_SUFFIX, rng)
+    render.floorSheet.DrawFeature(x, y, wall, render.mapImg)
+    return
+  }
+
+  if loc.hasFeature(GROUND_FEATURE) {
+    blend := render.grounds[loc.nearbyBiome] + BLEND_SUFFIX
+    render.floorSheet.DrawFeature(x, y, render.floorSheet.names[blend][0],
+                                  render.mapImg)
+  }
+
+  // Paths are dashed, with every other tile left bare.
+  if loc.hasFeature(PATH_FEATURE) {
+    if (x + y) % 2 == 0 {
+      path := PATH_SPRITES[GRASS_PATH]
+      if loc.biome == BEACH {
+        path = PATH_SPRITES[SAND_PATH]
+      }
+      render.pathSheet.DrawFeature(x, y, render.pathSheet.Variant(path, rng),
+                                   render.mapImg)
+    }
+  }
+
+  if !loc.isWater() {
+    shadows := []struct {
+      feature uint
+      name string
+    } {
+      { RIGHT_SHADOW_FEATURE, RIGHT_VERTICAL_SHADOW },
+      { LEFT_SHADOW_FEATURE, LEFT_VERTICAL_SHADOW },
+      { HORIZONTAL_SHADOW_FEATURE, HORIZONTAL_SHADOW },
+      { BOTTOM_LEFT_SHADOW_FEATURE, BOTTOM_LEFT_SHADOW },
+      { BOTTOM_RIGHT_SHADOW_FEATURE, BOTTOM_RIGHT_SHADOW },
+    }
+    for _, shadow := range shadows {
+      if loc.hasFeature(shadow.feature) {
+        render.shadowSheet.DrawFeature(x, y,
+                                       render.shadowSheet.names[shadow.name][0],
+                                       render.mapImg)
+      }
+    }
+  }
+  render.queueFeatures(loc, biome, x, y, rng, sprites)
+}
+
+// Queue the trees, rocks and plants of a location, chosen from those of the
+// biome, in 'sprites'.
+func (render *MapRenderer) queueFeatures(loc *Location, biome uint8, x, y int,
+                                         rng *rand.Rand,
+                                         sprites *[]SpriteDraw) {
+  if loc.hasFeature(LARGE_FEATURE) {
+    render.queueSprite(render.largeSheet, loc.largeSprite, x, y, rng, sprites)
+  } else if loc.hasFeature(TREE_FEATURE) {
+    trees := render.trees[biome]
+    if len(trees) != 0 {
+      tree := trees[rng.Intn(len(trees))]
+      render.queueSprite(render.treeSheet, tree, x, y, rng, sprites)
+    }
+  }
+  if loc.hasFeature(ROCK_FEATURE) && !loc.hasFeature(LARGE_FEATURE) {
+    rocks := render.rocks[biome]
+    if len(rocks) != 0 {
+      rock := rocks[rng.Intn(len(rocks))]
+      render.queueSprite(render.rockSheet, rock, x, y, rng, sprites)
+    }
+  }
+  if loc.hasFeature(PLANT_FEATURE) {
+    plants := render.plants[biome]
+    if len(plants) != 0 {
+      plant := plants[rng.Intn(len(plants))]
+      render.queueSprite(render.plantSheet, plant, x, y, rng, sprites)
+    }
+  }
+}
+
+// Queue a variant of the named sprite, or its replacement for the season,
+// unless it isn't drawn in this season at all.
+func (render *MapRenderer) queueSprite(sheet *SpriteSheet, name string,
+                                       x, y int, rng *rand.Rand,
+                                       sprites *[]SpriteDraw) {
+  name = sheet.manifest.Seasonal(name, render.season)
+  if name == NO_SPRITE {
+    return
+  }
+  anchor := sheet.manifest.Sprite(name).AnchorTile()
+  *sprites = append(*sprites, SpriteDraw{ x, y, sheet,
+                                          sheet.Variant(name, rng), anchor })
+}
+
+func (render *MapRenderer) DrawFloorTile(x, y int, biome uint8,
+                                         rng *rand.Rand) {
+  if render.waterSheet != nil && (biome == OCEAN || biome == RIVER) {
+    water := render.waterSheet.Variant(WATER_SPRITE, rng)
+    render.waterSheet.DrawFloorTile(x, y,
+                                    render.waterSheet.Frame(water, render.frame),
+                                    render.mapImg)
+    return
+  }
+  idx := render.floorSheet.Variant(render.grounds[biome], rng)
+  render.floorSheet.DrawFloorTile(x, y, idx, render.mapImg)
+}
+
+// A small random source which is cheap to reseed, so that every tile can
+// have its own sequence and make the same choices however the map is split
+// up to be drawn.
+type tileSource struct {
+  state uint64
+}
+
+func (s *tileSource) Seed(seed int64) {
+  s.state = uint64(seed)
+}
+
+// SplitMix64.
+func (s *tileSource) Uint64() uint64 {
+  s.state += 0x9e3779b97f4a7c15
+  z := s.state
+  z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+  z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+  return z ^ (z >> 31)
+}
+
+func (s *tileSource) Int63() int64 {
+  return int64(s.Uint64() >> 1)
+}
+
+func (render *MapRenderer) tileSeed(x, y int) int64 {
+  return render.seed ^ int64(uint64(x) * 0xc2b2ae3d27d4eb4f +
+                             uint64(y) * 0x165667b19e3779f9)
+}
+
+// Draw the ground of the tiles within the strip and the area being drawn,
+// and queue their sprites. Tiles just outside the area are visited too, so
+// that sprites which overhang it are included, but their ground is clipped.
+func (render *MapRenderer) ParallelDraw(w *World, xBegin, xEnd int, c chan int) {
+  visit := image.Rectangle{ render.area.Min.Sub(render.reach.Max),
+                            render.area.Max.Add(render.reach.Min) }
+  visit = visit.Intersect(image.Rect(xBegin, 0, xEnd, w.height))
+  rng := rand.New(new(tileSource))
+  sprites := make([]SpriteDraw, 0)
+  var err error
+  for y := visit.Min.Y; y < visit.Max.Y && err == nil; y++ {
+    for x := visit.Min.X; x < visit.Max.X; x++ {
+      rng.Seed(render.tileSeed(x, y))
+      biome := w.Biome(x, y)
+      loc := w.Location(x, y)
+
+      if loc.isRiverBank {
+        // OCEAN tiles don't have edge tiles, so use BEACH ones.
+        if biome == OCEAN {
+          biome = BEACH
+        }
+        if loc.isWall {
+          biome = RIVER
+        }
+        if err = render.DrawRiverBankFeature(x, y, loc.riverBank, biome);
+           err != nil {
+          break
+        }
+        render.DrawFeatures(loc, biome, x, y, rng, &sprites)
+      } else if loc.isRiver {
+        render.DrawFloorTile(x, y, RIVER, rng)
+        render.DrawFeatures(loc, RIVER, x, y, rng, &sprites)
+      } else {
+        render.DrawFloorTile(x, y, biome, rng)
+        render.DrawFeatures(loc, loc.biome, x, y, rng, &sprites)
+      }
+    }
+  }
+  render.spritesLock.Lock()
+  render.sprites = append(render.sprites, sprites...)
+  if render.err == nil {
+    render.err = err
+  }
+  render.spritesLock.Unlock()
+  c <- 1
+}
+
+// Draw the tiles within 'area', and any sprites which overhang them, into a
+// new image which just covers them.
+func (render *MapRenderer) DrawArea(w *World, area image.Rectangle,
+                                    numCPUs int) (*image.RGBA, error) {
+  render.area = area
+  render.err = nil
+  render.mapImg = image.NewRGBA(image.Rect(area.Min.X * render.tileWidth,
+                                           area.Min.Y * render.tileHeight,
+                                           area.Max.X * render.tileWidth,
+                                           area.Max.Y * render.tileHeight))
+  queue := CreateWorkQueue(w.width, numCPUs)
+  queue.Run(func(xBegin, xEnd int, c chan int) {
+              render.ParallelDraw(w, xBegin, xEnd, c)
+            })
+  if render.err != nil {
+    render.sprites = render.sprites[:0]
+    return nil, render.err
+  }
+  render.lighting.Shade(render.mapImg, render.tileWidth, render.tileHeight)
+  render.DrawSprites()
+  return render.mapImg, nil
+}
+
+// Draw the queued feature sprites from the top of the map down, so that the
+// ones further down overlap those behind them. Sprites are queued in order
+// within a tile, so a stable sort keeps that order.
+func (render *MapRenderer) DrawSprites() {
+  sort.SliceStable(render.sprites, func(i, j int) bool {
+    a := render.sprites[i]
+    b := render.sprites[j]
+    if a.y != b.y {
+      return a.y < b.y
+    }
+    return a.x < b.x
+  })
+  for _, s := range render.sprites {
+    s.sheet.DrawSprite(s.x, s.y, s.sheet.Frame(s.idx, render.frame), s.anchor,
+                       render.mapImg)
+  }
+  render.sprites = render.sprites[:0]
+}
+
+// Scale an image up by a whole number, repeating each pixel in a block.
+func Upscale(img image.Image, scale int) image.Image {
+  if scale == 1 {
+    return img
+  }
+  bounds := img.Bounds()
+  scaled := image.NewRGBA(image.Rect(0, 0, bounds.Dx() * scale,
+                                     bounds.Dy() * scale))
+  for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+    for x := bounds.Min.X; x < bounds.Max.X; x++ {
+      px := (x - bounds.Min.X) * scale
+      py := (y - bounds.Min.Y) * scale
+      draw.Draw(scaled, image.Rect(px, py, px + scale, py + scale),
+                &image.Uniform{ img.At(x, y) }, image.Point{}, draw.Src)
+    }
+  }
+  return scaled
+}
+
+// The colours of the overworld image.
+var BIOME_COLOURS = [BIOMES]color.RGBA{{ 51, 166, 204, 255 },  // OCEAN
+                                       { 0, 102, 102, 255 },   // RIVER
+                                       { 255, 230, 128, 255 }, // BEACH
+                                       { 204, 204, 204, 255 }, // DRY_ROCK
+                                       { 166, 166, 166, 255 }, // MOIST_ROCK
+                                       { 202, 218, 114, 255 }, // HEATHLAND
+                                       { 128, 153, 51, 255 },  // SHRUBLAND
+                                       { 170, 190, 50, 255 },  // GRASSLAND
+                                       { 217, 179, 255, 255 }, // MOORLAND
+                                       { 85, 128, 0, 255 },    // FENLAND
+                                       { 119, 179, 0, 255 },   // WOODLAND
+                                       { 77, 153, 0, 255 },    // FOREST
+                                       { 240, 245, 250, 255 }, // SNOW
+                                       { 150, 160, 130, 255 }, // TUNDRA
+                                       { 237, 190, 120, 255 }, // DESERT
+                                       { 206, 176, 80, 255 },  // SAVANNA
+                                       { 90, 120, 80, 255 },   // MARSH
+                                       { 20, 110, 50, 255 } }  // RAINFOREST
+
+// The colours of the locations with, or covered by, a tree or a rock.
+var TREE_COLOUR = color.RGBA{ 38, 77, 0, 255 }
+var ROCK_COLOUR = color.RGBA{ 220, 220, 220, 255 }
+
+// The colour of a location in the overworld: its biome, unless it holds, or
+// is covered by, a tree or rock.
+func overworldColour(w *World, x, y int) color.RGBA {
+  loc := w.Location(x, y)
+  if loc.coveredBy != nil {
+    loc = loc.coveredBy
+  }
+  if loc.hasFeature(TREE_FEATURE) {
+    return TREE_COLOUR
+  } else if loc.hasFeature(ROCK_FEATURE) {
+    return ROCK_COLOUR
+  }
+  return BIOME_COLOURS[w.Biome(x, y)]
+}
+
+// The renderers of the detailed maps of one season, which are created before
+// anything is written so that a bad tileset can't leave some of the outputs
+// behind.
+type SeasonMap struct {
+  season int
+  render *MapRenderer
+  iso *IsoRenderer
+}
+
+// Create the renderers for each season in the config, which loads and checks
+// every sheet they draw with.
+func CreateSeasonMaps(width, height int, seed int64, tileset *Tileset,
+                      config *Config) ([]SeasonMap, error) {
+  maps := make([]SeasonMap, 0, len(config.Seasons))
+  for _, season := range config.Seasons {
+    render, err := CreateMapRenderer(width, height, season, seed, tileset)
+    if err != nil {
+      return nil, err
+    }
+    if config.Animate != NO_ANIMATION && render.frames == 1 {
+      return nil, newError(ErrBadParameter,
+                           "the tileset has no animated sprites", nil)
+    }
+    var iso *IsoRenderer
+    if config.Iso {
+      iso, err = CreateIsoRenderer(width, height, season, seed, tileset)
+      if err != nil {
+        return nil, err
+      }
+    }
+    maps = append(maps, SeasonMap{ season, render, iso })
+  }
+  return maps, nil
+}
+
+// Draw each location of the world as a single pixel of its overworld colour,
+// lit by 'lighting', which can be nil.
+func DrawOverworld(w *World, lighting *Lighting) *image.RGBA {
+  overworld := image.NewRGBA(image.Rect(0, 0, w.width, w.height))
+  bounds := overworld.Bounds()
+  for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+    for x := bounds.Min.X; x < bounds.Max.X; x++ {
+      overworld.Set(x, y, LightColour(overworldColour(w, x, y),
+                                      lighting.At(x, y)))
+    }
+  }
+  return overworld
+}
+
+// Draw the overworld and the detailed maps of each season into the output.
+func DrawMap(w *World, maps []SeasonMap, config *Config,
+             output *Output) error {
+  // First, create an overworld image that represents each tile with a block
+  // of pixels.
+  lighting := CreateLighting(w, config.Hillshade, config.SunAzimuth,
+                             config.SunElevation, config.Occlusion)
+  overworld := DrawOverworld(w, lighting)
+
+  filename := output.Path("-overworld.png")
+  enc := &png.Encoder { CompressionLevel: png.BestSpeed, }
+  if err := writePNG(filename, Upscale(overworld, config.OverworldScale),
+                     enc); err != nil {
+    return err
+  }
+  fmt.Println("overworld image created.")
+  if config.Poster {
+    if err := DrawPoster(w, config.PosterScale,
+                         config.PosterLabels, output); err != nil {
+      return err
+    }
+  }
+
+  // Draw a detailed map for each season, naming them by season if there is
+  // more than one.
+  for _, m := range maps {
+    base := output.Path("-map")
+    if len(maps) > 1 {
+      base = output.Path("-map-" + SEASON_NAMES[m.season])
+    }
+    m.render.lighting = lighting
+    if err := DrawDetailedMap(w, m.render, config.Threads, config.Scale,
+                              config.Band, config.Split, config.Animate,
+                              base); err != nil {
+      return err
+    }
+    if m.iso != nil {
+      if err := DrawIsoMap(w, m.iso, config.Scale,
+                           base + "-iso.png"); err != nil {
+        return err
+      }
+    }
+  }
+  fmt.Println("Done!")
+  return nil
+}
+
+// Render the map, scaled up by 'scale', and save it to 'base' with a .png
+// extension. Animated tiles are either left on their first frame, saved as a
+// png for each frame, with the frame added to the name, or also saved as a
+// gif.
+func DrawDetailedMap(w *World, render *MapRenderer, numCPUs, scale, band,
+                     split, animate int, base string) error {
+  season := render.season
+
+  switch animate {
+  case FRAME_ANIMATION:
+    for frame := 0; frame < render.frames; frame++ {
+      render.frame = frame
+      if err := render.Save(w, numCPUs, scale, band, split,
+                            base + "-frame-" + strconv.Itoa(frame));
+         err != nil {
+        return err
+      }
+    }
+    fmt.Println("Detailed", SEASON_NAMES[season], "map frames created.")
+  case GIF_ANIMATION:
+    if err := render.Save(w, numCPUs, scale, band, split, base); err != nil {
+      return err
+    }
+    if err := render.SaveGIF(w, numCPUs, scale, base + ".gif"); err != nil {
+      return err
+    }
+    fmt.Println("Detailed", SEASON_NAMES[season], "map and animation created.")
+  default:
+    if err := render.Save(w, numCPUs, scale, band, split, base); err != nil {
+      return err
+    }
+    fmt.Println("Detailed", SEASON_NAMES[season], "map created.")
+  }
+  return nil
+}
+
+// Save the current frame of the map to 'base' with a .png extension. Only
+// 'band' rows of tiles are drawn at a time and streamed into the file, so
+// huge maps don't need to fit in memory. If 'split' is set, the map is
+// instead saved as separate files of split x split tiles, with their column
+// and row added to the name.
+func (render *MapRenderer) Save(w *World, numCPUs, scale, band, split int,
+                                base string) error {
+  if split > 0 {
+    enc := &png.Encoder { CompressionLevel: png.BestSpeed, }
+    for y := 0; y < w.height; y += split {
+      for x := 0; x < w.width; x += split {
+        area := image.Rect(x, y, x + split, y + split)
+        area = area.Intersect(image.Rect(0, 0, w.width, w.height))
+        img, err := render.DrawArea(w, area, numCPUs)
+        if err != nil {
+          return err
+        }
+        filename := base + "-" + strconv.Itoa(x / split) + "-" +
+                    strconv.Itoa(y / split) + ".png"
+        if err := writePNG(filename, Upscale(img, scale), enc); err != nil {
+          return err
+        }
+      }
+    }
+    return nil
+  }
+
+  filename := base + ".png"
+  file, err := os.Create(filename)
+  if err != nil {
+    return newError(ErrEncode, filename, err)
+  }
+  defer file.Close()
+  stream, err := CreatePNGStream(file, render.mapWidth * scale,
+                                 render.mapHeight * scale)
+  if err != nil {
+    return err
+  }
+  for y := 0; y < w.height; y += band {
+    yEnd := y + band
+    if yEnd > w.height {
+      yEnd = w.height
+    }
+    img, err := render.DrawArea(w, image.Rect(0, y, w.width, yEnd), numCPUs)
+    if err != nil {
+      return err
+    }
+    if err := stream.WriteRows(Upscale(img, scale)); err != nil {
+      return err
+    }
+  }
+  if err := stream.Close(); err != nil {
+    return err
+  }
+  if err := file.Close(); err != nil {
+    return newError(ErrEncode, filename, err)
+  }
+  return nil
+}
@@ -0,0 +1,58 @@
+package noisey
+
+import (
+  "errors"
+  "io/fs"
+)
+
+// The kinds of error returned by the generator. Every error is wrapped with
+// the details of what went wrong, so check for these with errors.Is.
+var (
+  // A spritesheet, manifest, heightmap or mask couldn't be found.
+  ErrMissingResource = errors.New("missing resource")
+  // A resource was found but couldn't be decoded or doesn't make sense.
+  ErrBadResource = errors.New("bad resource")
+  // A generation or rendering parameter is out of range or unrecognised.
+  ErrBadParameter = errors.New("bad parameter")
+  // No path could be found between two locations.
+  ErrNoPath = errors.New("path not found")
+  // An image or json output couldn't be encoded or written.
+  ErrEncode = errors.New("encode failure")
+)
+
+// An error of one of the kinds above, along with where it happened and, if
+// there is one, the error which caused it.
+type GenError struct {
+  Kind error
+  Detail string
+  Err error
+}
+
+func (e *GenError) Error() string {
+  msg := e.Kind.Error() + ": " + e.Detail
+  if e.Err != nil {
+    msg += ": " + e.Err.Error()
+  }
+  return msg
+}
+
+func (e *GenError) Is(target error) bool {
+  return target == e.Kind
+}
+
+func (e *GenError) Unwrap() error {
+  return e.Err
+}
+
+func newError(kind error, detail string, err error) error {
+  return &GenError{ kind, detail, err }
+}
+
+// Wrap an error from opening a resource, which is missing if it doesn't
+// exist and bad otherwise.
+func openError(filename string, err error) error {
+  if errors.Is(err, fs.ErrNotExist) {
+    return newError(ErrMissingResource, filename, err)
+  }
+  return newError(ErrBadResource, filename, err)
+}
@@ -0,0 +1,112 @@
+package noisey
+
+import (
+  "image"
+  "image/color"
+  "image/draw"
+  "unicode"
+)
+
+// A small bitmap font, of capitals, digits and a little punctuation, so that
+// text can be drawn without any font files. Lower case letters are drawn as
+// capitals and anything else as a question mark.
+const FONT_WIDTH = 5
+const FONT_HEIGHT = 7
+const FONT_ADVANCE = FONT_WIDTH + 1
+
+var FONT = map[rune][FONT_HEIGHT]string {
+  'A': { " ### ", "#   #", "#   #", "#####", "#   #", "#   #", "#   #" },
+  'B': { "#### ", "#   #", "#   #", "#### ", "#   #", "#   #", "#### " },
+  'C': { " ### ", "#   #", "#    ", "#    ", "#    ", "#   #", " ### " },
+  'D': { "#### ", "#   #", "#   #", "#   #", "#   #", "#   #", "#### " },
+  'E': { "#####", "#    ", "#    ", "#### ", "#    ", "#    ", "#####" },
+  'F': { "#####", "#    ", "#    ", "#### ", "#    ", "#    ", "#    " },
+  'G': { " ### ", "#   #", "#    ", "# ###", "#   #", "#   #", " ####" },
+  'H': { "#   #", "#   #", "#   #", "#####", "#   #", "#   #", "#   #" },
+  'I': { " ### ", "  #  ", "  #  ", "  #  ", "  #  ", "  #  ", " ### " },
+  'J': { "  ###", "   # ", "   # ", "   # ", "   # ", "#  # ", " ##  " },
+  'K': { "#   #", "#  # ", "# #  ", "##   ", "# #  ", "#  # ", "#   #" },
+  'L': { "#    ", "#    ", "#    ", "#    ", "#    ", "#    ", "#####" },
+  'M': { "#   #", "## ##", "# # #", "# # #", "#   #", "#   #", "#   #" },
+  'N': { "#   #", "#   #", "##  #", "# # #", "#  ##", "#   #", "#   #" },
+  'O': { " ### ", "#   #", "#   #", "#   #", "#   #", "#   #", " ### " },
+  'P': { "#### ", "#   #", "#   #", "#### ", "#    ", "#    ", "#    " },
+  'Q': { " ### ", "#   #", "#   #", "#   #", "# # #", "#  # ", " ## #" },
+  'R': { "#### ", "#   #", "#   #", "#### ", "# #  ", "#  # ", "#   #" },
+  'S': { " ####", "#    ", "#    ", " ### ", "    #", "    #", "#### " },
+  'T': { "#####", "  #  ", "  #  ", "  #  ", "  #  ", "  #  ", "  #  " },
+  'U': { "#   #", "#   #", "#   #", "#   #", "#   #", "#   #", " ### " },
+  'V': { "#   #", "#   #", "#   #", "#   #", "#   #", " # # ", "  #  " },
+  'W': { "#   #", "#   #", "#   #", "# # #", "# # #", "# # #", " # # " },
+  'X': { "#   #", "#   #", " # # ", "  #  ", " # # ", "#   #", "#   #" },
+  'Y': { "#   #", "#   #", " # # ", "  #  ", "  #  ", "  #  ", "  #  " },
+  'Z': { "#####", "    #", "   # ", "  #  ", " #   ", "#    ", "#####" },
+  '0': { " ### ", "#   #", "#  ##", "# # #", "##  #", "#   #", " ### " },
+  '1': { "  #  ", " ##  ", "  #  ", "  #  ", "  #  ", "  #  ", " ### " },
+  '2': { " ### ", "#   #", "    #", "   # ", "  #  ", " #   ", "#####" },
+  '3': { "#####", "   # ", "  #  ", "   # ", "    #", "#   #", " ### " },
+  '4': { "   # ", "  ## ", " # # ", "#  # ", "#####", "   # ", "   # " },
+  '5': { "#####", "#    ", "#### ", "    #", "    #", "#   #", " ### " },
+  '6': { "  ## ", " #   ", "#    ", "#### ", "#   #", "#   #", " ### " },
+  '7': { "#####", "    #", "   # ", "  #  ", " #   ", " #   ", " #   " },
+  '8': { " ### ", "#   #", "#   #", " ### ", "#   #", "#   #", " ### " },
+  '9': { " ### ", "#   #", "#   #", " ####", "    #", "   # ", " ##  " },
+  ' ': { "     ", "     ", "     ", "     ", "     ", "     ", "     " },
+  '-': { "     ", "     ", "     ", "#####", "     ", "     ", "     " },
+  '.': { "     ", "     ", "     ", "     ", "     ", "     ", "  #  " },
+  ',': { "     ", "     ", "     ", "     ", "     ", "  #  ", " #   " },
+  ':': { "     ", "  #  ", "     ", "     ", "     ", "  #  ", "     " },
+  '\'': { "  #  ", "  #  ", "     ", "     ", "     ", "     ", "     " },
+  '(': { "   # ", "  #  ", " #   ", " #   ", " #   ", "  #  ", "   # " },
+  ')': { " #   ", "  #  ", "   # ", "   # ", "   # ", "  #  ", " #   " },
+  '/': { "    #", "    #", "   # ", "  #  ", " #   ", "#    ", "#    " },
+  '?': { " ### ", "#   #", "    #", "   # ", "  #  ", "     ", "  #  " },
+}
+
+// The width, in pixels, of text drawn at 'scale'.
+func TextWidth(text string, scale int) int {
+  n := len([]rune(text))
+  if n == 0 {
+    return 0
+  }
+  return (n * FONT_ADVANCE - 1) * scale
+}
+
+// Draw text with its top left corner at x, y, with each pixel of the font
+// drawn as a scale x scale block.
+func DrawText(img draw.Image, x, y int, text string, scale int,
+              c color.Color) {
+  src := &image.Uniform{ c }
+  for _, r := range text {
+    glyph, ok := FONT[unicode.ToUpper(r)]
+    if !ok {
+      glyph = FONT['?']
+    }
+    for gy, row := range glyph {
+      for gx, pixel := range row {
+        if pixel != '#' {
+          continue
+        }
+        px := x + gx * scale
+        py := y + gy * scale
+        draw.Draw(img, image.Rect(px, py, px + scale, py + scale), src,
+                  image.Point{}, draw.Src)
+      }
+    }
+    x += FONT_ADVANCE * scale
+  }
+}
+
+// Draw text with a one pixel outline around it, so it can be read over the
+// map.
+func DrawOutlinedText(img draw.Image, x, y int, text string, scale int,
+                      c, outline color.Color) {
+  for dy := -1; dy <= 1; dy++ {
+    for dx := -1; dx <= 1; dx++ {
+      if dx != 0 || dy != 0 {
+        DrawText(img, x + dx, y + dy, text, scale, outline)
+      }
+    }
+  }
+  DrawText(img, x, y, text, scale, c)
+}
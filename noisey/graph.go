@@ -1,4 +1,4 @@
-package main
+package noisey
 
 import (
   "math"
@@ -80,11 +80,12 @@ func (g *Graph) populateNeighbours(loc *Location) {
   node := g.getNode(loc)
 
   if loc.isWall {
-    neighbour := w.Location(loc.x, loc.y - 1)
+    neighbour := w.WrappedLocation(loc.x, loc.y - 1)
     node.numNeighbours = 0
 
-    if neighbour.isRiver || neighbour.isRiverBank ||
-       neighbour.hasFeature(TREE_FEATURE) || neighbour.hasFeature(ROCK_FEATURE) {
+    if neighbour == nil || neighbour.isRiver || neighbour.isRiverBank ||
+       neighbour.hasFeature(TREE_FEATURE) || neighbour.hasFeature(ROCK_FEATURE) ||
+       neighbour.coveredBy != nil {
       return
     }
     nodeNeighbour := g.getNode(neighbour)
@@ -103,17 +104,17 @@ func (g *Graph) populateNeighbours(loc *Location) {
       if x != 0 && y != 0 {
         continue
       }
+      neighbour := w.WrappedLocation(loc.x + x, loc.y + y)
       // out of range
-      if loc.x + x < 0 || loc.x + x >= w.width ||
-         loc.y + y < 0 || loc.y + y >= w.height {
+      if neighbour == nil {
         continue
       }
-      neighbour := w.Location(loc.x + x, loc.y + y)
 
       if neighbour.isRiver || neighbour.isRiverBank {
         continue
       }
-      if neighbour.hasFeature(TREE_FEATURE) || neighbour.hasFeature(ROCK_FEATURE) {
+      if neighbour.hasFeature(TREE_FEATURE) || neighbour.hasFeature(ROCK_FEATURE) ||
+         neighbour.coveredBy != nil {
         continue
       }
 
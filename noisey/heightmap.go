@@ -0,0 +1,48 @@
+package noisey
+
+import (
+  "image/png"
+  "os"
+)
+
+// A hand painted height map, resampled to the size of the world, which can
+// replace or be mixed with the noise generated heights.
+type Heightmap struct {
+  heights []float64
+  width int
+  mix float64
+}
+
+// Load an 8 or 16-bit grayscale png, mapping black to 'min' and white to
+// 'max'. 'mix' is the proportion of the noise generated height which is
+// blended into the imported height, from 0 for none to 1 for noise only.
+func LoadHeightmap(filename string, width, height int,
+                   min, max, mix float64) (*Heightmap, error) {
+  file, err := os.Open(filename)
+  if err != nil {
+    return nil, openError(filename, err)
+  }
+  defer file.Close()
+  img, err := png.Decode(file)
+  if err != nil {
+    return nil, newError(ErrBadResource, filename, err)
+  }
+
+  hm := new(Heightmap)
+  hm.width = width
+  hm.mix = mix
+  hm.heights = sampleGray(img, width, height)
+  for i, v := range hm.heights {
+    hm.heights[i] = min + v * (max - min)
+  }
+  return hm, nil
+}
+
+func (hm *Heightmap) Height(x, y int) float64 {
+  return hm.heights[y * hm.width + x]
+}
+
+// Combine the imported height at x, y with the noise generated height.
+func (hm *Heightmap) Blend(x, y int, noise float64) float64 {
+  return (1 - hm.mix) * hm.Height(x, y) + hm.mix * noise
+}
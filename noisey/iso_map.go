@@ -0,0 +1,216 @@
+package noisey
+
+import (
+  "fmt"
+  "image"
+  "image/color"
+  "image/png"
+  "math/rand"
+  "sort"
+)
+
+// The faces below the two front edges of a raised tile, which are rock for
+// walls and earth for every other drop.
+const (
+  ISO_CLIFF_LEFT = "cliff_left"
+  ISO_CLIFF_RIGHT = "cliff_right"
+  ISO_SIDE_LEFT = "side_left"
+  ISO_SIDE_RIGHT = "side_right"
+)
+
+// IsoRenderer draws the map from an isometric camera. Each tile of the
+// isometric sheet is the diamond of one location, twice as wide as it is
+// high, and each face sprite fills the same cell when drawn half a tile
+// below the diamond. The ground of each biome is the sprite with the same
+// name as its ground in the floor manifest. Every terrace raises the ground
+// by half a tile's
+// height. Trees, rocks and plants are chosen, and drawn, from the same
+// sheets as the top down map.
+type IsoRenderer struct {
+  flat *MapRenderer
+  sheet *SpriteSheet
+  tileWidth, tileHeight, step int
+  // The height, in pixels, of the tallest sprite.
+  tallest int
+  // Space above the top of the map for raised tiles and tall sprites.
+  top int
+  img *image.RGBA
+}
+
+// Create an isometric renderer from the tileset, which needs an isometric
+// sheet, for a map of width x height tiles in the given season.
+func CreateIsoRenderer(width, height, season int, seed int64,
+                       tileset *Tileset) (*IsoRenderer, error) {
+  if tileset.Iso == "" {
+    return nil, newError(ErrBadResource,
+                         "the tileset has no isometric sheet", nil)
+  }
+  flat, err := CreateMapRenderer(width, height, season, seed, tileset)
+  if err != nil {
+    return nil, err
+  }
+  sheet, err := LoadSheet(tileset.resources, tileset.Iso)
+  if err != nil {
+    return nil, err
+  }
+  if sheet.tileHeight % 2 != 0 {
+    return nil, newError(ErrBadResource, tileset.Iso +
+                         ": tile height must be even", nil)
+  }
+  if err := sheet.manifest.Require(flat.grounds[:]...); err != nil {
+    return nil, err
+  }
+  if err := sheet.manifest.Require(ISO_CLIFF_LEFT, ISO_CLIFF_RIGHT,
+                                   ISO_SIDE_LEFT, ISO_SIDE_RIGHT); err != nil {
+    return nil, err
+  }
+  // Tint the ground for the season, as with the top down floor.
+  water := make(map[color.NRGBA]bool)
+  for _, biome := range []int{ OCEAN, RIVER } {
+    for _, idx := range sheet.names[flat.grounds[biome]] {
+      for c := range sheet.Palette(idx) {
+        water[c] = true
+      }
+    }
+  }
+  sheet = sheet.Tint(SEASON_TINTS[season], water)
+
+  render := new(IsoRenderer)
+  render.flat = flat
+  render.sheet = sheet
+  render.tileWidth = sheet.tileWidth
+  render.tileHeight = sheet.tileHeight
+  render.step = sheet.tileHeight / 2
+  for _, s := range []*SpriteSheet{ flat.treeSheet, flat.rockSheet,
+                                    flat.plantSheet, flat.largeSheet } {
+    for _, entry := range s.manifest.Sprites {
+      if h := entry.TileSize().Y * s.tileHeight; h > render.tallest {
+        render.tallest = h
+      }
+    }
+  }
+  return render, nil
+}
+
+// The top left corner of the diamond of location x, y.
+func (render *IsoRenderer) project(w *World, x, y int) image.Point {
+  return image.Pt((x - y + w.height - 1) * render.tileWidth / 2,
+                  render.top + (x + y) * render.tileHeight / 2 -
+                  int(w.Terrace(x, y)) * render.step)
+}
+
+// The location at x, y or nil if it is off the map, which is never wrapped
+// as the edges are drawn apart.
+func (render *IsoRenderer) location(w *World, x, y int) *Location {
+  if x < 0 || y < 0 || x >= w.width || y >= w.height {
+    return nil
+  }
+  return w.Location(x, y)
+}
+
+// Draw the faces below a tile's front edges, down to the terrace of the
+// location in front of each edge or to the bottom of the map at its edges.
+func (render *IsoRenderer) drawFaces(w *World, loc *Location, pos image.Point) {
+  faces := []struct {
+    front *Location
+    cliff, side string
+  } {
+    { render.location(w, loc.x, loc.y + 1), ISO_CLIFF_LEFT, ISO_SIDE_LEFT },
+    { render.location(w, loc.x + 1, loc.y), ISO_CLIFF_RIGHT, ISO_SIDE_RIGHT },
+  }
+  for _, face := range faces {
+    below := 0
+    name := face.side
+    if face.front != nil {
+      below = int(face.front.terrace)
+      // Walls are the drops towards the south, which are drawn as cliffs, as
+      // are drops to the east.
+      if (face.front.y != loc.y && loc.isWall) ||
+         (face.front.x != loc.x && int(loc.terrace) > below) {
+        name = face.cliff
+      }
+    }
+    idx := render.sheet.names[name][0]
+    for level := below; level < int(loc.terrace); level++ {
+      drop := (int(loc.terrace) - level - 1) * render.step
+      render.sheet.DrawAt(pos.X, pos.Y + render.tileHeight / 2 + drop, idx,
+                          render.img)
+    }
+  }
+}
+
+// Draw the whole map. The diagonals of the map are drawn from the back to
+// the front, each with its ground first and then its sprites from left to
+// right, so that raised ground and sprites cover whatever is behind them.
+func (render *IsoRenderer) Draw(w *World) *image.RGBA {
+  maxTerrace := 0
+  for y := 0; y < w.height; y++ {
+    for x := 0; x < w.width; x++ {
+      if t := int(w.Terrace(x, y)); t > maxTerrace {
+        maxTerrace = t
+      }
+    }
+  }
+  render.top = render.tallest + maxTerrace * render.step
+  diagonals := w.width + w.height - 1
+  render.img = image.NewRGBA(image.Rect(0, 0,
+                                        diagonals * render.tileWidth / 2 +
+                                        render.tileWidth / 2,
+                                        render.top + diagonals *
+                                        render.tileHeight / 2 +
+                                        render.tileHeight / 2))
+  flat := render.flat
+  rng := rand.New(new(tileSource))
+  sprites := make([]SpriteDraw, 0)
+  for d := 0; d < diagonals; d++ {
+    sprites = sprites[:0]
+    for x := 0; x < w.width; x++ {
+      y := d - x
+      if y < 0 || y >= w.height {
+        continue
+      }
+      rng.Seed(flat.tileSeed(x, y))
+      loc := w.Location(x, y)
+      biome := w.Biome(x, y)
+      if loc.isRiver {
+        biome = RIVER
+      }
+      pos := render.project(w, x, y)
+      render.drawFaces(w, loc, pos)
+      floor := render.sheet.Variant(flat.grounds[biome], rng)
+      render.sheet.DrawAt(pos.X, pos.Y, floor, render.img)
+      flat.queueFeatures(loc, loc.biome, x, y, rng, &sprites)
+    }
+    sort.SliceStable(sprites, func(i, j int) bool {
+      return sprites[i].x < sprites[j].x
+    })
+    for _, s := range sprites {
+      render.drawSprite(w, s)
+    }
+  }
+  return render.img
+}
+
+// Draw a top down sprite standing on its location, with the bottom middle of
+// its anchor tile in the middle of the location's diamond.
+func (render *IsoRenderer) drawSprite(w *World, s SpriteDraw) {
+  pos := render.project(w, s.x, s.y)
+  foot := image.Pt(s.anchor.X * s.sheet.tileWidth + s.sheet.tileWidth / 2,
+                   (s.anchor.Y + 1) * s.sheet.tileHeight)
+  centre := pos.Add(image.Pt(render.tileWidth / 2, render.tileHeight / 2))
+  at := centre.Sub(foot)
+  s.sheet.DrawAt(at.X, at.Y, s.sheet.Frame(s.idx, 0), render.img)
+}
+
+// Render the map isometrically, scaled up by 'scale', and save it to
+// 'filename'. Unlike the top down map, the whole image is drawn at once.
+func DrawIsoMap(w *World, render *IsoRenderer, scale int,
+                filename string) error {
+  img := render.Draw(w)
+  enc := &png.Encoder { CompressionLevel: png.BestSpeed, }
+  if err := writePNG(filename, Upscale(img, scale), enc); err != nil {
+    return err
+  }
+  fmt.Println("Isometric", SEASON_NAMES[render.flat.season], "map created.")
+  return nil
+}
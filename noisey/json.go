@@ -1,8 +1,7 @@
-package main
+package noisey
 
 import (
   "encoding/json"
-  "log"
   "os"
 )
 
@@ -13,32 +12,35 @@ type ExportLoc struct {
 
 type ExportWorld struct {
   Width, Height int
+  WrapX, WrapY bool
   Locations []ExportLoc
 }
 
-func ExportJSON(w *World) {
-  file, err := os.Create("world.json")
+func ExportJSON(w *World, output *Output) error {
+  filename := output.Path("-world.json")
+  file, err := os.Create(filename)
   if err != nil {
-    log.Fatal(err)
+    return newError(ErrEncode, filename, err)
   }
   defer file.Close()
 
   exportWorld := new(ExportWorld)
   exportWorld.Width = w.width
   exportWorld.Height = w.height
+  exportWorld.WrapX = w.wrapX
+  exportWorld.WrapY = w.wrapY
   exportWorld.Locations = make([]ExportLoc, w.width * w.height)
 
   for y := 0; y < w.height; y++ {
     for x := 0; x < w.width; x++ {
       loc := w.Location(x, y)
-      blocked := (loc.isRiver || loc.isWall || loc.biome == OCEAN ||
-                  loc.hasFeature(ROCK_FEATURE) ||
-                  loc.hasFeature(TREE_FEATURE))
-      exportWorld.Locations[y * w.width + x] = ExportLoc{ loc.x, loc.y, blocked }
+      exportWorld.Locations[y * w.width + x] = ExportLoc{ loc.x, loc.y,
+                                                          loc.isBlocked() }
     }
   }
   enc := json.NewEncoder(file)
   if err := enc.Encode(exportWorld); err != nil {
-    log.Println(err)
+    return newError(ErrEncode, filename, err)
   }
+  return nil
 }
@@ -0,0 +1,106 @@
+package noisey
+
+import (
+  "fmt"
+  "image"
+  "math/rand"
+)
+
+// The kinds of feature which a large sprite, from large_features.json, can
+// replace in the biomes it is tagged with. A large sprite's size is in tiles
+// and its anchor is the tile of the sprite which sits on the feature's
+// location; the rest of the sprite extends up and to the right of it. Its
+// footprint is the block of tiles that the feature stands on and blocks. Any
+// other tiles under the sprite are overhung but stay walkable.
+const (
+  LARGE_TREE = iota
+  LARGE_ROCK
+  NUM_LARGE_KINDS
+)
+
+var LARGE_KINDS = [NUM_LARGE_KINDS]string {
+  "tree",
+  "rock",
+}
+
+// The large sprites which replace the kind of feature in each biome.
+func largeSprites(manifest *SpriteManifest, kind int) [BIOMES][]string {
+  return manifest.BiomeSprites(func(e *SpriteEntry) bool {
+    return e.Replaces == LARGE_KINDS[kind]
+  })
+}
+
+// The locations under the sprite, if it would fit with its anchor at loc.
+// Each one has to be on the map, on the same terrace and free of water,
+// walls and other features.
+func (w *World) largeSpriteTiles(loc *Location, sprite *SpriteEntry) []*Location {
+  size := sprite.TileSize()
+  anchor := sprite.AnchorTile()
+  tiles := make([]*Location, 0, size.X * size.Y)
+  for sy := 0; sy < size.Y; sy++ {
+    for sx := 0; sx < size.X; sx++ {
+      x := loc.x + sx - anchor.X
+      y := loc.y + sy - anchor.Y
+      // Sprites aren't wrapped around the edges of the map.
+      if x < 0 || x >= w.width || y < 0 || y >= w.height {
+        return nil
+      }
+      tile := w.Location(x, y)
+      if tile != loc {
+        if isObstacle(tile) || tile.isRiverBank || tile.coveredBy != nil ||
+           tile.hasFeature(TREE_FEATURE) || tile.hasFeature(ROCK_FEATURE) ||
+           tile.hasFeature(PLANT_FEATURE) || tile.terrace != loc.terrace {
+          return nil
+        }
+      }
+      tiles = append(tiles, tile)
+    }
+  }
+  return tiles
+}
+
+// Replace some of the trees and rocks with large versions, where there is
+// room for them, with the given chance. The footprint of each one is
+// reserved, so nothing else can be placed on it and it becomes blocked.
+func (w *World) AddLargeFeatures(chance float64, seed int64,
+                                 manifest *SpriteManifest) {
+  if chance <= 0 {
+    return
+  }
+  largeTrees := largeSprites(manifest, LARGE_TREE)
+  largeRocks := largeSprites(manifest, LARGE_ROCK)
+  rng := rand.New(rand.NewSource(seed))
+  count := 0
+  for y := 0; y < w.height; y++ {
+    for x := 0; x < w.width; x++ {
+      loc := w.Location(x, y)
+      var choices []string
+      if loc.hasFeature(TREE_FEATURE) {
+        choices = largeTrees[loc.biome]
+      } else if loc.hasFeature(ROCK_FEATURE) {
+        choices = largeRocks[loc.biome]
+      }
+      if len(choices) == 0 || loc.coveredBy != nil || rng.Float64() >= chance {
+        continue
+      }
+      name := choices[rng.Intn(len(choices))]
+      sprite := manifest.Sprite(name)
+      tiles := w.largeSpriteTiles(loc, sprite)
+      if tiles == nil {
+        continue
+      }
+      loc.addFeature(LARGE_FEATURE)
+      loc.largeSprite = name
+      size := sprite.TileSize()
+      footprint := sprite.FootprintTiles()
+      for i, tile := range tiles {
+        p := image.Pt(i % size.X, i / size.X)
+        if p.In(footprint) {
+          tile.coveredBy = loc
+        }
+      }
+      count++
+    }
+  }
+  fmt.Println("Added", count, "large features")
+}
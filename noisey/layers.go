@@ -0,0 +1,180 @@
+package noisey
+
+import (
+  "encoding/json"
+  "fmt"
+  "image"
+  "image/color"
+  "image/png"
+  "math"
+  "os"
+)
+
+// How the pixels of a layer image map back to the values of the layer:
+// - linear: a pixel of 0 is Min and the largest pixel value is Max.
+// - log: as linear, but between log(1 + Min) and log(1 + Max).
+// - index: the pixel value is the value, with optional names in Values.
+const (
+  LINEAR_SCALE = "linear"
+  LOG_SCALE = "log"
+  INDEX_SCALE = "index"
+)
+
+// Fixed ranges so that the same value always maps to the same pixel, whatever
+// the world. The tree, plant and rock layers instead span the range of their
+// noise.
+const HEIGHT_MIN = -3.0
+const HEIGHT_MAX = 2.0
+const TEMPERATURE_MIN = -40.0
+const TEMPERATURE_MAX = 40.0
+const SOIL_MIN = -4.0
+const SOIL_MAX = 2.0
+const WATER_MAX = 10000.0
+
+const RIVER_MASK = 255
+
+// A description of one exported layer, written into the sidecar file.
+type LayerMapping struct {
+  Name string
+  File string
+  Bits int
+  Scale string
+  Min, Max float64
+  Values map[int]string `json:",omitempty"`
+}
+
+type ExportLayerSet struct {
+  Width, Height int
+  Layers []LayerMapping
+}
+
+// Write an image to a png file with the given encoder.
+func writePNG(filename string, img image.Image, enc *png.Encoder) error {
+  imgFile, err := os.Create(filename)
+  if err != nil {
+    return newError(ErrEncode, filename, err)
+  }
+  if err := enc.Encode(imgFile, img); err != nil {
+    imgFile.Close();
+    return newError(ErrEncode, filename, err)
+  }
+  if err := imgFile.Close(); err != nil {
+    return newError(ErrEncode, filename, err)
+  }
+  return nil
+}
+
+// Write a 16-bit grayscale image, with one pixel per location, where values
+// between min and max are mapped from black to white, either linearly or on
+// a log scale.
+func ExportLayer(w *World, output *Output, name, scale string,
+                 min, max float64,
+                 value func(loc *Location) float64) (LayerMapping, error) {
+  lo, hi := min, max
+  if scale == LOG_SCALE {
+    lo, hi = math.Log1p(min), math.Log1p(max)
+  }
+  img := image.NewGray16(image.Rect(0, 0, w.width, w.height))
+  for y := 0; y < w.height; y++ {
+    for x := 0; x < w.width; x++ {
+      v := value(w.Location(x, y))
+      if scale == LOG_SCALE {
+        v = math.Log1p(math.Max(v, 0))
+      }
+      v = math.Max(0, math.Min(1, (v - lo) / (hi - lo)))
+      img.SetGray16(x, y, color.Gray16{ uint16(math.Round(v * 0xffff)) })
+    }
+  }
+  suffix := "-layer-" + name + ".png"
+  if err := writePNG(output.Path(suffix), img, new(png.Encoder)); err != nil {
+    return LayerMapping{}, err
+  }
+  return LayerMapping{ Name: name, File: output.Name(suffix), Bits: 16, Scale: scale,
+                       Min: min, Max: max }, nil
+}
+
+// Write an 8-bit grayscale image, with one pixel per location, where each
+// pixel holds the value directly.
+func ExportIndexLayer(w *World, output *Output, name string,
+                      values map[int]string,
+                      value func(loc *Location) uint8) (LayerMapping, error) {
+  img := image.NewGray(image.Rect(0, 0, w.width, w.height))
+  for y := 0; y < w.height; y++ {
+    for x := 0; x < w.width; x++ {
+      img.SetGray(x, y, color.Gray{ value(w.Location(x, y)) })
+    }
+  }
+  suffix := "-layer-" + name + ".png"
+  if err := writePNG(output.Path(suffix), img, new(png.Encoder)); err != nil {
+    return LayerMapping{}, err
+  }
+  return LayerMapping{ Name: name, File: output.Name(suffix), Bits: 8, Scale: INDEX_SCALE,
+                       Min: 0, Max: math.MaxUint8, Values: values }, nil
+}
+
+// Write each of the data layers as a separate image, along with a sidecar
+// json file describing how to read the values back from them.
+func ExportLayers(w *World, output *Output) error {
+  biomes := make(map[int]string, BIOMES)
+  for i, name := range BIOME_NAMES {
+    biomes[i] = name
+  }
+
+  layers := make([]LayerMapping, 0)
+  var err error
+  // Keep the first error, if any of the layers can't be written.
+  add := func(layer LayerMapping, layerErr error) {
+    if err == nil {
+      err = layerErr
+    }
+    layers = append(layers, layer)
+  }
+  add(ExportLayer(w, output, "height", LINEAR_SCALE, HEIGHT_MIN, HEIGHT_MAX,
+                  func(loc *Location) float64 { return loc.height }))
+  add(ExportLayer(w, output, "moisture", LOG_SCALE, 0, WATER_MAX,
+                  func(loc *Location) float64 { return loc.moisture }))
+  add(ExportLayer(w, output, "temperature", LINEAR_SCALE, TEMPERATURE_MIN,
+                  TEMPERATURE_MAX,
+                  func(loc *Location) float64 { return loc.temperature }))
+  add(ExportLayer(w, output, "soil", LINEAR_SCALE, SOIL_MIN, SOIL_MAX,
+                  func(loc *Location) float64 { return loc.soil }))
+  add(ExportLayer(w, output, "tree", LINEAR_SCALE, -w.treeRange, w.treeRange,
+                  func(loc *Location) float64 { return loc.tree }))
+  add(ExportLayer(w, output, "plant", LINEAR_SCALE, -w.plantRange,
+                  w.plantRange,
+                  func(loc *Location) float64 { return loc.plant }))
+  add(ExportLayer(w, output, "rock", LINEAR_SCALE, -w.rockRange, w.rockRange,
+                  func(loc *Location) float64 { return loc.rock }))
+  add(ExportLayer(w, output, "flow", LOG_SCALE, 0, WATER_MAX,
+                  func(loc *Location) float64 { return loc.flow }))
+  add(ExportIndexLayer(w, output, "terrace", nil,
+                       func(loc *Location) uint8 { return loc.terrace }))
+  add(ExportIndexLayer(w, output, "biome", biomes,
+                       func(loc *Location) uint8 { return loc.biome }))
+  add(ExportIndexLayer(w, output, "river", map[int]string{ 0: "land",
+                                                   RIVER_MASK: "river" },
+                       func(loc *Location) uint8 {
+                         if loc.isRiver {
+                           return RIVER_MASK
+                         }
+                         return 0
+                       }))
+
+  if err != nil {
+    return err
+  }
+
+  filename := output.Path("-layers.json")
+  file, err := os.Create(filename)
+  if err != nil {
+    return newError(ErrEncode, filename, err)
+  }
+  defer file.Close()
+  enc := json.NewEncoder(file)
+  enc.SetIndent("", "  ")
+  if err := enc.Encode(ExportLayerSet{ w.width, w.height, layers }); err != nil {
+    return newError(ErrEncode, filename, err)
+  }
+  fmt.Println("layer images created.")
+  return nil
+}
@@ -0,0 +1,172 @@
+package noisey
+
+import (
+  "image"
+  "image/color"
+  "math"
+)
+
+// How many tiles one unit of height covers, which exaggerates the gentle
+// slopes of the height noise enough to be seen.
+const HILLSHADE_Z = 20.0
+
+// How much light is blocked, before scaling by the strength of the ambient
+// occlusion, at the foot of a wall and under a tree.
+const WALL_OCCLUSION = 1.0
+const TREE_OCCLUSION = 0.6
+
+// The most that ambient occlusion can darken a location by.
+const MAX_OCCLUSION = 0.5
+
+// Lighting holds how bright each location is, from hillshading by a distant
+// sun and from ambient occlusion, where 1 leaves the colour as it is. A nil
+// Lighting leaves every image untouched.
+type Lighting struct {
+  width, height int
+  wrapX, wrapY bool
+  light []float64
+}
+
+// The height which is lit, with the sea flattened to its surface.
+func litHeight(loc *Location) float64 {
+  if loc.biome == OCEAN {
+    return math.Max(loc.height, WATER_LEVEL)
+  }
+  return loc.height
+}
+
+// Calculate the lighting of the world, or return nil if both the hillshade
+// and occlusion are off. The sun's azimuth is in degrees clockwise from
+// north and its elevation is in degrees above the horizon. Each strength
+// scales its effect, where zero turns it off.
+func CreateLighting(w *World, hillshade, azimuth, elevation,
+                    occlusion float64) *Lighting {
+  if hillshade == 0 && occlusion == 0 {
+    return nil
+  }
+  lighting := new(Lighting)
+  lighting.width = w.width
+  lighting.height = w.height
+  lighting.wrapX = w.wrapX
+  lighting.wrapY = w.wrapY
+  lighting.light = make([]float64, w.width * w.height)
+
+  // The direction of the sun, with x to the east, y to the south and z up.
+  a := azimuth * math.Pi / 180
+  e := elevation * math.Pi / 180
+  sunX := math.Sin(a) * math.Cos(e)
+  sunY := -math.Cos(a) * math.Cos(e)
+  sunZ := math.Sin(e)
+
+  // Sample the height at x, y, clamped to the edges of the map unless they
+  // wrap.
+  height := func(x, y int) float64 {
+    if loc := w.WrappedLocation(x, y); loc != nil {
+      return litHeight(loc)
+    }
+    if x < 0 {
+      x = 0
+    } else if x >= w.width {
+      x = w.width - 1
+    }
+    if y < 0 {
+      y = 0
+    } else if y >= w.height {
+      y = w.height - 1
+    }
+    return litHeight(w.Location(x, y))
+  }
+
+  for y := 0; y < w.height; y++ {
+    for x := 0; x < w.width; x++ {
+      light := 1.0
+      if hillshade != 0 {
+        dx := (height(x + 1, y) - height(x - 1, y)) / 2 * HILLSHADE_Z
+        dy := (height(x, y + 1) - height(x, y - 1)) / 2 * HILLSHADE_Z
+        // The dot product of the surface normal, (-dx, -dy, 1), with the sun,
+        // compared with the light falling on flat ground.
+        lit := (-dx * sunX - dy * sunY + sunZ) / math.Sqrt(dx * dx + dy * dy + 1)
+        light += hillshade * (math.Max(lit, 0) - sunZ)
+      }
+      if occlusion != 0 {
+        loc := w.Location(x, y)
+        blocked := 0.0
+        if north := w.WrappedLocation(x, y - 1); north != nil && north.isWall {
+          blocked = WALL_OCCLUSION
+        } else if loc.hasFeature(TREE_FEATURE) ||
+                  (loc.coveredBy != nil &&
+                   loc.coveredBy.hasFeature(TREE_FEATURE)) {
+          blocked = TREE_OCCLUSION
+        }
+        light *= 1 - math.Min(occlusion * blocked, 1) * MAX_OCCLUSION
+      }
+      lighting.light[y * w.width + x] = math.Max(light, 0)
+    }
+  }
+  return lighting
+}
+
+// The light at location x, y.
+func (l *Lighting) At(x, y int) float64 {
+  if l == nil {
+    return 1
+  }
+  return l.light[y * l.width + x]
+}
+
+// The light at a point in tiles, where the middle of location x, y is at
+// x + 0.5, y + 0.5, blended between the four nearest locations. Past the
+// edges of the map, the light wraps around the axes which wrap and is held
+// at the edge of the others.
+func (l *Lighting) Sample(fx, fy float64) float64 {
+  fx -= 0.5
+  fy -= 0.5
+  x0 := int(math.Floor(fx))
+  y0 := int(math.Floor(fy))
+  tx := fx - float64(x0)
+  ty := fy - float64(y0)
+  at := func(x, y int) float64 {
+    if l.wrapX {
+      x = (x % l.width + l.width) % l.width
+    } else {
+      x = clampInt(x, 0, l.width - 1)
+    }
+    if l.wrapY {
+      y = (y % l.height + l.height) % l.height
+    } else {
+      y = clampInt(y, 0, l.height - 1)
+    }
+    return l.light[y * l.width + x]
+  }
+  top := at(x0, y0) * (1 - tx) + at(x0 + 1, y0) * tx
+  bottom := at(x0, y0 + 1) * (1 - tx) + at(x0 + 1, y0 + 1) * tx
+  return top * (1 - ty) + bottom * ty
+}
+
+func lightChannel(c uint8, light float64, max uint8) uint8 {
+  return uint8(math.Min(float64(c) * light, float64(max)))
+}
+
+// Light a colour, brightening it when the light is above 1.
+func LightColour(c color.RGBA, light float64) color.RGBA {
+  return color.RGBA{ lightChannel(c.R, light, c.A),
+                     lightChannel(c.G, light, c.A),
+                     lightChannel(c.B, light, c.A), c.A }
+}
+
+// Light every pixel of part of a detailed map, where each tile is
+// tileWidth x tileHeight pixels and the image's bounds are in pixels from
+// the top left of the whole map.
+func (l *Lighting) Shade(img *image.RGBA, tileWidth, tileHeight int) {
+  if l == nil {
+    return
+  }
+  bounds := img.Bounds()
+  for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+    fy := (float64(y) + 0.5) / float64(tileHeight)
+    for x := bounds.Min.X; x < bounds.Max.X; x++ {
+      fx := (float64(x) + 0.5) / float64(tileWidth)
+      img.SetRGBA(x, y, LightColour(img.RGBAAt(x, y), l.Sample(fx, fy)))
+    }
+  }
+}
@@ -0,0 +1,29 @@
+package noisey
+
+import "testing"
+
+// Between the last and first columns, the light blends across the wrap
+// where the world wraps and stays at the edge's where it doesn't.
+func TestLightingSampleWrap(t *testing.T) {
+  for _, wrap := range []bool{ false, true } {
+    l := &Lighting{ width: 4, height: 2, wrapX: wrap, wrapY: wrap,
+                    light: []float64{ 1, 0.5, 0.5, 0,
+                                      0, 0.5, 0.5, 1 } }
+    want := 0.0
+    if wrap {
+      want = 0.5
+    }
+    // On the right hand edge of the map, halfway between the middles of
+    // the last and first columns.
+    if got := l.Sample(4, 0.5); got != want {
+      t.Errorf("wrap %v, across x: got %v, want %v", wrap, got, want)
+    }
+    want = 1
+    if wrap {
+      want = 0.5
+    }
+    if got := l.Sample(3.5, 2); got != want {
+      t.Errorf("wrap %v, across y: got %v, want %v", wrap, got, want)
+    }
+  }
+}
@@ -1,4 +1,4 @@
-package main
+package noisey
 
 const (
   EMPTY = 0
@@ -15,6 +15,8 @@ const (
   RIGHT_WATER_SHADOW_FEATURE = 1 << 9
   GROUND_FEATURE = 1 << 10
   PATH_FEATURE = 1 << 11
+  // A tree or rock which is drawn with a sprite covering several tiles.
+  LARGE_FEATURE = 1 << 12
 
 )
 
@@ -42,11 +44,38 @@ const (
   FENLAND
   WOODLAND
   FOREST
+  SNOW
+  TUNDRA
+  DESERT
+  SAVANNA
+  MARSH
+  RAINFOREST
   BIOMES
 )
 
-func biome(h, m float64) uint8 {
-  // Height, Moisture and Soil Depth
+var BIOME_NAMES = [BIOMES]string {
+  "ocean",
+  "river",
+  "beach",
+  "dry rock",
+  "moist rock",
+  "heathland",
+  "shrubland",
+  "grassland",
+  "moorland",
+  "fenland",
+  "woodland",
+  "forest",
+  "snow",
+  "tundra",
+  "desert",
+  "savanna",
+  "marsh",
+  "rainforest",
+}
+
+func biome(h, m, t, s float64) uint8 {
+  // Height, Moisture, Temperature and Soil Depth
 
   // - bare rock
   // - lichen rock
@@ -70,8 +99,39 @@ func biome(h, m float64) uint8 {
     return BEACH
   }
 
+  // Temperature overrides height at either extreme.
+  if t < SNOW_LINE {
+    return SNOW
+  } else if t < FREEZING {
+    return TUNDRA
+  } else if t > HOT {
+    if m > SODDEN {
+      return RAINFOREST
+    } else if m > ARID {
+      return SAVANNA
+    } else {
+      return DESERT
+    }
+  }
+
+  if m > SODDEN {
+    if h < LOWLANDS {
+      return MARSH
+    } else if h < MIDLANDS && t > WARM && s > THICK_SOIL {
+      return RAINFOREST
+    }
+  }
+
+  // Without any soil, only bare rock remains.
+  if s < NO_SOIL {
+    if m > MOIST {
+      return MOIST_ROCK
+    }
+    return DRY_ROCK
+  }
+
   if h > HIGHLANDS {
-    if m > WET {
+    if m > WET && s > SHALLOW_SOIL {
       return MOORLAND
     } else if m > MOIST {
       return MOIST_ROCK
@@ -79,7 +139,7 @@ func biome(h, m float64) uint8 {
       return DRY_ROCK
     }
   } else if h > MIDLANDS {
-    if m > WET {
+    if m > WET && s > THICK_SOIL {
       return FOREST
     } else if m > MOIST {
       return WOODLAND
@@ -87,7 +147,9 @@ func biome(h, m float64) uint8 {
       return SHRUBLAND
     }
   } else if h > LOWLANDS {
-    if m > WET {
+    if s < SHALLOW_SOIL {
+      return HEATHLAND
+    } else if m > WET {
       return FENLAND
     } else if m > MOIST {
       return SHRUBLAND
@@ -95,7 +157,9 @@ func biome(h, m float64) uint8 {
       return GRASSLAND
     }
   } else if h > BEACH_LEVEL {
-    if m > WET {
+    if s < SHALLOW_SOIL {
+      return HEATHLAND
+    } else if m > WET {
       return SHRUBLAND
     } else if m > MOIST {
       return GRASSLAND
@@ -107,7 +171,7 @@ func biome(h, m float64) uint8 {
 }
 
 type Location struct {
-  height, moisture, tree, rock, plant float64
+  height, moisture, temperature, soil, tree, rock, plant, flow float64
   neighbours [4]*Location
   numNeighbours int
   totalGradient float64
@@ -119,6 +183,9 @@ type Location struct {
   isRiver bool
   isWall bool
   riverBank uint
+  largeSprite string
+  // The location of the large feature whose footprint covers this one.
+  coveredBy *Location
 }
 
 type ByHeight []Location
@@ -144,6 +211,12 @@ func (l *Location) hasFeature(feat uint) bool {
   return feat & l.features == feat
 }
 
+// Whether a feature stands on the location, so it can't be walked through.
+func (l *Location) isBlocked() bool {
+  return l.isRiver || l.isWall || l.biome == OCEAN || l.coveredBy != nil ||
+         l.hasFeature(ROCK_FEATURE) || l.hasFeature(TREE_FEATURE)
+}
+
 func (l *Location) setRiverBank(feat uint) {
   l.isRiverBank = true
   l.riverBank = feat
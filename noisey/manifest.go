@@ -0,0 +1,194 @@
+package noisey
+
+import (
+  "encoding/json"
+  "image"
+  "io/fs"
+)
+
+// One named sprite within a sheet. Every cell, given in tiles as column and
+// row, holds a variant of the sprite and one is picked at random wherever it
+// is drawn. A sprite covers a single tile unless it has a size; a larger
+// sprite is drawn with its anchor tile on the location and blocks the tiles
+// of its footprint, given as x0, y0, x1, y1 within the sprite, which is the
+// whole sprite unless set.
+type SpriteEntry struct {
+  Name string `json:"name"`
+  Cells [][2]int `json:"cells"`
+  Size *[2]int `json:"size,omitempty"`
+  Anchor [2]int `json:"anchor,omitempty"`
+  Footprint *[4]int `json:"footprint,omitempty"`
+  // The biomes, by name, that the sprite can be chosen in.
+  Biomes []string `json:"biomes,omitempty"`
+  // The kind of feature, tree or rock, which a large sprite can replace.
+  Replaces string `json:"replaces,omitempty"`
+  // The sprite drawn in its place in each season, by name, where an empty
+  // name means it isn't drawn at all.
+  Seasons map[string]string `json:"seasons,omitempty"`
+  // An animated sprite has the offset, in tiles, of each of its frames from
+  // each of its cells, and shows every frame for Duration milliseconds.
+  Frames [][2]int `json:"frames,omitempty"`
+  Duration int `json:"duration,omitempty"`
+}
+
+// A description of a sheet of sprites, read from a json file alongside the
+// image, so that new art can be added without code changes.
+type SpriteManifest struct {
+  Image string `json:"image"`
+  TileWidth int `json:"tileWidth"`
+  TileHeight int `json:"tileHeight"`
+  Sprites []SpriteEntry `json:"sprites"`
+  byName map[string]*SpriteEntry
+}
+
+func indexOf(names []string, name string) int {
+  for i, n := range names {
+    if n == name {
+      return i
+    }
+  }
+  return -1
+}
+
+// Load and check a manifest from the resources.
+func LoadManifest(resources fs.FS, filename string) (*SpriteManifest, error) {
+  file, err := resources.Open(filename)
+  if err != nil {
+    return nil, openError(filename, err)
+  }
+  defer file.Close()
+
+  m := new(SpriteManifest)
+  if err := json.NewDecoder(file).Decode(m); err != nil {
+    return nil, newError(ErrBadResource, filename, err)
+  }
+  if m.TileWidth <= 0 || m.TileHeight <= 0 {
+    return nil, newError(ErrBadResource,
+                         filename + ": tile size must be positive", nil)
+  }
+  m.byName = make(map[string]*SpriteEntry, len(m.Sprites))
+  for i := range m.Sprites {
+    entry := &m.Sprites[i]
+    if len(entry.Cells) == 0 {
+      return nil, newError(ErrBadResource, filename + ": sprite '" +
+                           entry.Name + "' has no cells", nil)
+    }
+    if len(entry.Frames) != 0 && entry.Duration <= 0 {
+      return nil, newError(ErrBadResource, filename + ": animated sprite '" +
+                           entry.Name + "' needs a positive duration", nil)
+    }
+    m.byName[entry.Name] = entry
+  }
+  for _, entry := range m.Sprites {
+    for _, biome := range entry.Biomes {
+      if indexOf(BIOME_NAMES[:], biome) == -1 {
+        return nil, newError(ErrBadResource, filename + ": unknown biome '" +
+                             biome + "'", nil)
+      }
+    }
+    if entry.Replaces != "" && indexOf(LARGE_KINDS[:], entry.Replaces) == -1 {
+      return nil, newError(ErrBadResource, filename + ": sprite '" +
+                           entry.Name + "' can only replace a tree or rock",
+                           nil)
+    }
+    for season, name := range entry.Seasons {
+      if indexOf(SEASON_NAMES[:], season) == -1 {
+        return nil, newError(ErrBadResource, filename + ": unknown season '" +
+                             season + "'", nil)
+      }
+      if name != NO_SPRITE && m.byName[name] == nil {
+        return nil, newError(ErrBadResource, filename + ": unknown sprite '" +
+                             name + "'", nil)
+      }
+    }
+  }
+  return m, nil
+}
+
+// The named sprite, or nil if the manifest doesn't have it.
+func (m *SpriteManifest) Sprite(name string) *SpriteEntry {
+  return m.byName[name]
+}
+
+// Check that every one of the names is in the manifest.
+func (m *SpriteManifest) Require(names ...string) error {
+  for _, name := range names {
+    if m.Sprite(name) == nil {
+      return newError(ErrBadResource, m.Image + ": no sprite named '" +
+                      name + "'", nil)
+    }
+  }
+  return nil
+}
+
+func gcd(a, b int) int {
+  for b != 0 {
+    a, b = b, a % b
+  }
+  return a
+}
+
+// The number of frames it takes for every animated sprite in the manifest to
+// loop at once, and the shortest duration of any of their frames, which is
+// zero if none of them are animated.
+func (m *SpriteManifest) Animation() (frames, duration int) {
+  frames = 1
+  for _, entry := range m.Sprites {
+    if len(entry.Frames) == 0 {
+      continue
+    }
+    frames = frames / gcd(frames, len(entry.Frames)) * len(entry.Frames)
+    if duration == 0 || entry.Duration < duration {
+      duration = entry.Duration
+    }
+  }
+  return frames, duration
+}
+
+// The size of the sprite in tiles.
+func (e *SpriteEntry) TileSize() image.Point {
+  if e.Size == nil {
+    return image.Pt(1, 1)
+  }
+  return image.Pt(e.Size[0], e.Size[1])
+}
+
+func (e *SpriteEntry) AnchorTile() image.Point {
+  return image.Pt(e.Anchor[0], e.Anchor[1])
+}
+
+// The tiles the sprite blocks, relative to its top left.
+func (e *SpriteEntry) FootprintTiles() image.Rectangle {
+  if e.Footprint == nil {
+    return image.Rectangle{ image.Point{}, e.TileSize() }
+  }
+  return image.Rect(e.Footprint[0], e.Footprint[1],
+                    e.Footprint[2], e.Footprint[3])
+}
+
+// The sprites to choose from in each biome, which are those tagged with it,
+// in the order they appear in the manifest. If 'match' is given, only the
+// sprites it matches are included.
+func (m *SpriteManifest) BiomeSprites(match func(e *SpriteEntry) bool) (
+  biomes [BIOMES][]string) {
+  for i := range m.Sprites {
+    entry := &m.Sprites[i]
+    if match != nil && !match(entry) {
+      continue
+    }
+    for _, name := range entry.Biomes {
+      biome := indexOf(BIOME_NAMES[:], name)
+      biomes[biome] = append(biomes[biome], entry.Name)
+    }
+  }
+  return biomes
+}
+
+// The sprite to draw in place of 'name', which has to be in the manifest, in
+// the season.
+func (m *SpriteManifest) Seasonal(name string, season int) string {
+  if replacement, ok := m.Sprite(name).Seasons[SEASON_NAMES[season]]; ok {
+    return replacement
+  }
+  return name
+}
@@ -0,0 +1,205 @@
+package noisey
+
+import (
+  "fmt"
+)
+
+// Statistics gathered for a single step of the moisture simulation.
+type MoistureStats struct {
+  step int
+  active int
+  split, refused int
+  merged int
+  dried, exited int
+  rainfall float64
+}
+
+// MoistureSim owns the queue of clouds that are blown across the world. Each
+// step moves every active cloud by one tile; clouds that split at terraces are
+// queued for the following step and clouds that end up on the same tile,
+// travelling in the same direction, can be merged back together.
+type MoistureSim struct {
+  world *World
+  clouds []*Cloud
+  spawned []*Cloud
+  maxClouds int
+  maxDistance int
+  merge bool
+  verbose bool
+  // Called after each step, if set, with the number of steps taken.
+  onStep func(step int)
+  current MoistureStats
+  stats []MoistureStats
+}
+
+type cloudKey struct {
+  loc *Location
+  dir uint
+}
+
+// Create a simulation with a row or column of clouds, each carrying 'water',
+// along the edge of the map that the wind blows from. A maxClouds of zero, or
+// less, allows an unlimited number of clouds.
+func CreateMoistureSim(w *World, water float64, maxClouds int,
+                       merge, verbose bool) *MoistureSim {
+  sim := new(MoistureSim)
+  sim.world = w
+  sim.maxClouds = maxClouds
+  // No cloud can cross a map without wrapping in more steps than this, so it
+  // only limits clouds that are going around a wrapping map.
+  sim.maxDistance = w.width
+  if w.height > w.width {
+    sim.maxDistance = w.height
+  }
+  sim.merge = merge
+  sim.verbose = verbose
+
+  width := w.width
+  height := w.height
+  windDir := w.windDir
+  numClouds := 0
+  sx := 0
+  sy := 0
+  ex := 0
+  ey := 0
+  if windDir == NORTH {
+    numClouds = width
+    sx = 0
+    sy = height - 1
+    ex = width - 1
+    ey = height - 1
+  } else if windDir == SOUTH {
+    numClouds = width
+    sx = 0
+    sy = 0
+    ex = width - 1
+    ey = 0
+  } else if windDir == EAST {
+    numClouds = height
+    sx = 0
+    sy = 0
+    ex = 0
+    ey = height - 1
+  } else if windDir == WEST {
+    numClouds = height
+    sx = width - 1
+    sy = 0
+    ex = width - 1
+    ey = height - 1
+  }
+
+  sim.clouds = make([]*Cloud, 0, numClouds)
+  for x := sx; x <= ex; x++ {
+    for y := sy; y <= ey; y++ {
+      loc := w.Location(x, y)
+      sim.clouds = append(sim.clouds, CreateCloud(water, windDir, loc, sim))
+    }
+  }
+  return sim
+}
+
+func (sim *MoistureSim) numClouds() int {
+  return len(sim.clouds) + len(sim.spawned)
+}
+
+// Request a new cloud, travelling in 'dir' from the parent's location, which
+// takes 'moisture' from the parent. Returns false if the cloud couldn't be
+// created, either because it would leave the map or the cap has been reached.
+func (sim *MoistureSim) split(parent *Cloud, dir uint, moisture float64) bool {
+  if sim.maxClouds > 0 && sim.numClouds() >= sim.maxClouds {
+    sim.current.refused++
+    return false
+  }
+  loc := sim.world.getDirectedLocation(parent.loc, dir)
+  if loc == nil {
+    sim.current.refused++
+    return false
+  }
+  sim.spawned = append(sim.spawned, CreateCloud(moisture, dir, loc, sim))
+  sim.current.split++
+  return true
+}
+
+func (sim *MoistureSim) rain(loc *Location, moisture float64) {
+  loc.moisture += moisture
+  sim.current.rainfall += moisture
+}
+
+// Combine clouds which share a location and direction.
+func (sim *MoistureSim) mergeClouds() {
+  merged := make(map[cloudKey]*Cloud, len(sim.clouds))
+  clouds := sim.clouds[:0]
+  for _, c := range sim.clouds {
+    key := cloudKey{ c.loc, c.direction }
+    if existing, ok := merged[key]; ok {
+      existing.moisture += c.moisture
+      sim.current.merged++
+      continue
+    }
+    merged[key] = c
+    clouds = append(clouds, c)
+  }
+  sim.clouds = clouds
+}
+
+// Move every cloud by one tile. Returns whether any clouds remain.
+func (sim *MoistureSim) Step() bool {
+  sim.current = MoistureStats{ step: len(sim.stats),
+                               active: len(sim.clouds) }
+  sim.spawned = sim.spawned[:0]
+
+  remaining := make([]*Cloud, 0, len(sim.clouds))
+  for _, c := range sim.clouds {
+    if !c.update() {
+      remaining = append(remaining, c)
+    } else if c.moisture <= 0 {
+      sim.current.dried++
+    } else {
+      sim.current.exited++
+    }
+  }
+  sim.clouds = append(remaining, sim.spawned...)
+  sim.spawned = sim.spawned[:0]
+
+  if sim.merge {
+    sim.mergeClouds()
+  }
+
+  sim.stats = append(sim.stats, sim.current)
+  if sim.verbose {
+    s := sim.current
+    fmt.Printf("step %d: clouds %d, split %d, refused %d, merged %d, " +
+               "dried %d, exited %d, rainfall %.2f\n",
+               s.step, s.active, s.split, s.refused, s.merged,
+               s.dried, s.exited, s.rainfall)
+  }
+  return len(sim.clouds) != 0
+}
+
+// Run the simulation until every cloud has either dried up or left the map.
+func (sim *MoistureSim) Run() {
+  for sim.Step() {
+    if sim.onStep != nil {
+      sim.onStep(len(sim.stats))
+    }
+  }
+
+  var total MoistureStats
+  peak := 0
+  for _, s := range sim.stats {
+    total.split += s.split
+    total.refused += s.refused
+    total.merged += s.merged
+    total.dried += s.dried
+    total.exited += s.exited
+    total.rainfall += s.rainfall
+    if s.active > peak {
+      peak = s.active
+    }
+  }
+  fmt.Println("moisture steps:", len(sim.stats), "peak clouds:", peak)
+  fmt.Println("clouds split:", total.split, "refused:", total.refused,
+              "merged:", total.merged)
+  fmt.Println("clouds dried:", total.dried, "exited:", total.exited,
+              "rainfall:", total.rainfall)
+}
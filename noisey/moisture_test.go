@@ -0,0 +1,119 @@
+package noisey
+
+import (
+  "math"
+  "testing"
+)
+
+// A world of flat land with the clouds blowing north from the bottom row,
+// where every row above 'rise' is a terrace higher than the rows below.
+func risingWorld(width, height, rise int) *World {
+  w := CreateWorld(&Config{ Width: width, Height: height, WindDir: NORTH })
+  for y := 0; y < height; y++ {
+    for x := 0; x < width; x++ {
+      w.SetHeight(x, y, 1)
+      if y < rise {
+        w.SetTerrace(x, y, 1)
+      }
+    }
+  }
+  return w
+}
+
+// The moisture rained onto the world and still carried by the clouds.
+func totalMoisture(w *World, sim *MoistureSim) float64 {
+  total := 0.0
+  for i := range w.locations {
+    total += w.locations[i].moisture
+  }
+  for _, c := range sim.clouds {
+    total += c.moisture
+  }
+  return total
+}
+
+func checkMoisture(t *testing.T, name string, got, want float64) {
+  if math.Abs(got - want) > 1e-9 {
+    t.Errorf("%s: got %v moisture, want %v", name, got, want)
+  }
+}
+
+// A cloud which rises onto a higher terrace splits in two, and the new
+// clouds carry on with their share of its moisture.
+func TestMoistureSplit(t *testing.T) {
+  w := risingWorld(5, 6, 3)
+  sim := CreateMoistureSim(w, 100, 0, false, false)
+  sim.clouds = []*Cloud{ CreateCloud(100, NORTH, w.Location(2, 5), sim) }
+  for i := 0; i < 3; i++ {
+    sim.Step()
+  }
+  if got := sim.stats[2].split; got != 2 {
+    t.Errorf("got %d splits at the rise, want 2", got)
+  }
+  if got := len(sim.clouds); got != 3 {
+    t.Fatalf("got %d clouds after the rise, want 3", got)
+  }
+  // The new clouds start from the diagonals ahead of where the parent was.
+  for i, x := range []int{ 2, 3, 1 } {
+    c := sim.clouds[i]
+    if c.loc != w.Location(x, 2) || c.moisture <= 0 {
+      t.Errorf("got a cloud at %d,%d with %v moisture, want one at %d,2",
+               c.loc.x, c.loc.y, c.moisture, x)
+    }
+  }
+  checkMoisture(t, "after the split", totalMoisture(w, sim), 100)
+
+  // And carry on along them.
+  sim.Step()
+  for i, x := range []int{ 2, 4, 0 } {
+    if c := sim.clouds[i]; c.loc != w.Location(x, 1) {
+      t.Errorf("got a cloud at %d,%d, want one at %d,1", c.loc.x, c.loc.y, x)
+    }
+  }
+}
+
+// No more than maxClouds are ever alive, and the splits beyond it are
+// refused, leaving their moisture with the parent.
+func TestMoistureMaxClouds(t *testing.T) {
+  w := risingWorld(8, 6, 3)
+  sim := CreateMoistureSim(w, 100, 10, false, false)
+  water := totalMoisture(w, sim)
+  split, refused := 0, 0
+  for step := 0; sim.Step(); step++ {
+    if got := len(sim.clouds); got > 10 {
+      t.Fatalf("step %d: got %d clouds", step, got)
+    }
+    split += sim.current.split
+    refused += sim.current.refused
+    if sim.current.exited == 0 {
+      checkMoisture(t, "before any exit", totalMoisture(w, sim), water)
+    }
+  }
+  if split != 2 || refused == 0 {
+    t.Errorf("got %d splits and %d refused, want 2 splits and some refused",
+             split, refused)
+  }
+}
+
+// Clouds which reach the same location travelling the same way become one,
+// with their moisture combined, unless merging is off.
+func TestMoistureMerge(t *testing.T) {
+  for _, merge := range []bool{ false, true } {
+    w := risingWorld(3, 4, 0)
+    sim := CreateMoistureSim(w, 50, 0, merge, false)
+    sim.clouds = []*Cloud{ CreateCloud(50, NORTH, w.Location(1, 3), sim),
+                           CreateCloud(50, NORTH, w.Location(1, 3), sim) }
+    sim.Step()
+    want := 2
+    if merge {
+      want = 1
+    }
+    if got := len(sim.clouds); got != want {
+      t.Errorf("merge %v: got %d clouds, want %d", merge, got, want)
+    }
+    if got := sim.stats[0].merged; got != 2 - want {
+      t.Errorf("merge %v: got %d merged", merge, got)
+    }
+    checkMoisture(t, "merged", totalMoisture(w, sim), 100)
+  }
+}
@@ -0,0 +1,238 @@
+package noisey
+
+import (
+  "math"
+)
+
+// Ways of combining the octaves of a fractal.
+const (
+  // Fractional brownian motion: a plain sum of the octaves.
+  FBM_NOISE = iota
+  // The absolute value of each octave, giving rounded, puffy, features.
+  BILLOW_NOISE
+  // Inverted absolute values, where each octave is weighted by the previous
+  // one, giving sharp ridges.
+  RIDGED_NOISE
+  // fBm sampled at a position which has itself been displaced by fBm,
+  // giving swirling, organic, features.
+  WARP_NOISE
+  NUM_NOISE_MODES
+)
+
+var NOISE_MODE_NAMES = [NUM_NOISE_MODES]string {
+  "fbm",
+  "billow",
+  "ridged",
+  "warp",
+}
+
+// The parameters for building a fractal noise layer.
+type NoiseConfig struct {
+  Source string
+  Mode string
+  Octaves int
+  Freq, Gain, Lacunarity, Persistence, Warp float64
+}
+
+// Fractal noise built from several octaves of a noise source. Each octave is
+// sampled at 'lacunarity' times the frequency of the previous one and
+// contributes 'persistence' times its amplitude, starting from one, except
+// that the first octave contributes 'gain'.
+type Fractal struct {
+  noise Noise2D
+  mode int
+  octaves int
+  freq, gain, lacunarity, persistence, warp float64
+  wrapX, wrapY bool
+}
+
+// A source which can also be sampled in four dimensions, which is needed to
+// wrap it seamlessly around both axes.
+type Noise4D interface {
+  Eval4(x, y, z, w float64) float64
+}
+
+// The four octave fBm used for layers without their own noise settings.
+func DefaultNoise(freq float64) NoiseConfig {
+  return NoiseConfig{ Source: "simplex", Mode: "fbm", Octaves: 4, Freq: freq, Gain: 1,
+                      Lacunarity: 2, Persistence: 0.5 }
+}
+
+// If 'wrapX' or 'wrapY' are set, the fractal repeats seamlessly across the
+// range [0, 1) on that axis.
+func CreateFractal(noise Noise2D, config NoiseConfig,
+                   wrapX, wrapY bool) (*Fractal, error) {
+  mode := -1
+  for i, name := range NOISE_MODE_NAMES {
+    if name == config.Mode {
+      mode = i
+    }
+  }
+  if mode == -1 {
+    return nil, newError(ErrBadParameter,
+                         "Invalid noise mode '" + config.Mode +
+                         "', choose: fbm,billow,ridged or warp", nil)
+  }
+  if config.Octaves < 1 {
+    return nil, newError(ErrBadParameter,
+                         "Noise needs at least one octave", nil)
+  }
+
+  f := new(Fractal)
+  f.noise = noise
+  f.mode = mode
+  f.octaves = config.Octaves
+  f.freq = config.Freq
+  f.gain = config.Gain
+  f.lacunarity = config.Lacunarity
+  f.persistence = config.Persistence
+  f.warp = config.Warp
+  f.wrapX = wrapX
+  f.wrapY = wrapY
+  // The layers are scaled by the amplitude, so at least one octave has to
+  // contribute.
+  if amplitude := f.Amplitude(); !(amplitude > 0) ||
+     math.IsInf(amplitude, 1) {
+    return nil, newError(ErrBadParameter,
+                         "Noise needs a gain and persistence which give " +
+                         "the octaves finite weights, not all zero", nil)
+  }
+  return f, nil
+}
+
+// Create the noise source named in the config, seeded with 'seed', and build
+// a fractal from it.
+func CreateLayerNoise(config NoiseConfig, seed int64,
+                      wrapX, wrapY bool) (*Fractal, error) {
+  noise, err := CreateNoise(config.Source, seed)
+  if err != nil {
+    return nil, err
+  }
+  return CreateFractal(noise, config, wrapX, wrapY)
+}
+
+// The largest magnitude the fractal can produce, which is the sum of the
+// amplitudes of its octaves.
+func (f *Fractal) Amplitude() float64 {
+  total := 0.0
+  amplitude := 1.0
+  for i := 0; i < f.octaves; i++ {
+    total += math.Abs(f.weight(i, amplitude))
+    amplitude *= f.persistence
+  }
+  return total
+}
+
+// The contribution of octave i, which is 'amplitude' for all but the first.
+func (f *Fractal) weight(i int, amplitude float64) float64 {
+  if i == 0 {
+    return f.gain
+  }
+  return amplitude
+}
+
+// Map one axis onto a pair of coordinates. A wrapped axis becomes a circle,
+// with a circumference of 'freq', so that features keep the same size.
+func torusAxis(freq, v float64, wrap bool) (float64, float64) {
+  if !wrap {
+    return freq * v, 0
+  }
+  radius := freq / (2 * math.Pi)
+  angle := 2 * math.Pi * v
+  return radius * math.Cos(angle), radius * math.Sin(angle)
+}
+
+// Sample a single octave of the source at 'freq'.
+func (f *Fractal) sample(freq, x, y float64) float64 {
+  if !f.wrapX && !f.wrapY {
+    return f.noise.Eval2(freq * x, freq * y)
+  }
+  // Sample the surface of a torus, or a cylinder, in 4D space.
+  if n, ok := f.noise.(Noise4D); ok {
+    a, b := torusAxis(freq, x, f.wrapX)
+    c, d := torusAxis(freq, y, f.wrapY)
+    return n.Eval4(a, b, c, d)
+  }
+  // Otherwise blend the noise with a copy of itself, shifted by the whole
+  // map, so it fades into the opposite edge.
+  if f.wrapX {
+    x -= math.Floor(x)
+  }
+  if f.wrapY {
+    y -= math.Floor(y)
+  }
+  row := func(y float64) float64 {
+    n := f.noise.Eval2(freq * x, freq * y)
+    if !f.wrapX {
+      return n
+    }
+    return lerp(n, f.noise.Eval2(freq * (x - 1), freq * y), x)
+  }
+  if !f.wrapY {
+    return row(y)
+  }
+  return lerp(row(y), row(y - 1), y)
+}
+
+func (f *Fractal) fbm(x, y float64) float64 {
+  total := 0.0
+  amplitude := 1.0
+  freq := f.freq
+  for i := 0; i < f.octaves; i++ {
+    total += f.weight(i, amplitude) * f.sample(freq, x, y)
+    amplitude *= f.persistence
+    freq *= f.lacunarity
+  }
+  return total
+}
+
+func (f *Fractal) billow(x, y float64) float64 {
+  total := 0.0
+  amplitude := 1.0
+  freq := f.freq
+  for i := 0; i < f.octaves; i++ {
+    n := f.sample(freq, x, y)
+    total += f.weight(i, amplitude) * (2 * math.Abs(n) - 1)
+    amplitude *= f.persistence
+    freq *= f.lacunarity
+  }
+  return total
+}
+
+func (f *Fractal) ridged(x, y float64) float64 {
+  total := 0.0
+  amplitude := 1.0
+  weight := 1.0
+  freq := f.freq
+  for i := 0; i < f.octaves; i++ {
+    signal := 1 - math.Abs(f.sample(freq, x, y))
+    signal *= signal * weight
+    weight = math.Max(0, math.Min(1, 2 * signal))
+    total += f.weight(i, amplitude) * signal
+    amplitude *= f.persistence
+    freq *= f.lacunarity
+  }
+  // Each signal is in [0, 1], so centre the result around zero.
+  return 2 * total - f.Amplitude()
+}
+
+func (f *Fractal) warped(x, y float64) float64 {
+  // Offset the second sample so the two displacements aren't correlated.
+  qx := f.fbm(x, y)
+  qy := f.fbm(x + 5.2, y + 1.3)
+  return f.fbm(x + f.warp * qx, y + f.warp * qy)
+}
+
+// Sample the fractal at x, y, which are normally the map coordinates scaled
+// to the range [0, 1).
+func (f *Fractal) Eval(x, y float64) float64 {
+  switch f.mode {
+  case BILLOW_NOISE:
+    return f.billow(x, y)
+  case RIDGED_NOISE:
+    return f.ridged(x, y)
+  case WARP_NOISE:
+    return f.warped(x, y)
+  }
+  return f.fbm(x, y)
+}
@@ -0,0 +1,155 @@
+package noisey
+
+import (
+  "math"
+  "math/rand"
+)
+
+import "github.com/ojrac/opensimplex-go"
+
+// A source of coherent noise, which returns values roughly in the range
+// [-1, 1]. opensimplex.Noise already satisfies this.
+type Noise2D interface {
+  Eval2(x, y float64) float64
+}
+
+const PERM_SIZE = 256
+
+// A shuffled table of 0 to 255, repeated once so that lookups of the form
+// perm[perm[x] + y] don't need to wrap.
+type permutation [2 * PERM_SIZE]int
+
+func createPermutation(seed int64) *permutation {
+  p := new(permutation)
+  rng := rand.New(rand.NewSource(seed))
+  for i, v := range rng.Perm(PERM_SIZE) {
+    p[i] = v
+    p[i + PERM_SIZE] = v
+  }
+  return p
+}
+
+// A pseudo random value, in [0, PERM_SIZE), for an integer lattice point.
+func (p *permutation) hash(x, y int) int {
+  return p[p[x & (PERM_SIZE - 1)] + (y & (PERM_SIZE - 1))]
+}
+
+// Smoothly ease between 0 and 1, with a flat gradient at both ends.
+func fade(t float64) float64 {
+  return t * t * t * (t * (t * 6 - 15) + 10)
+}
+
+func lerp(a, b, t float64) float64 {
+  return a + t * (b - a)
+}
+
+// Classic gradient noise, with one of eight gradients at each lattice point.
+type PerlinNoise struct {
+  perm *permutation
+}
+
+func CreatePerlinNoise(seed int64) *PerlinNoise {
+  n := new(PerlinNoise)
+  n.perm = createPermutation(seed)
+  return n
+}
+
+func (n *PerlinNoise) grad(ix, iy int, dx, dy float64) float64 {
+  switch n.perm.hash(ix, iy) & 7 {
+  case 0: return dx + dy
+  case 1: return dx - dy
+  case 2: return -dx + dy
+  case 3: return -dx - dy
+  case 4: return math.Sqrt2 * dx
+  case 5: return -math.Sqrt2 * dx
+  case 6: return math.Sqrt2 * dy
+  }
+  return -math.Sqrt2 * dy
+}
+
+func (n *PerlinNoise) Eval2(x, y float64) float64 {
+  x0 := math.Floor(x)
+  y0 := math.Floor(y)
+  ix, iy := int(x0), int(y0)
+  dx, dy := x - x0, y - y0
+  u, v := fade(dx), fade(dy)
+  top := lerp(n.grad(ix, iy, dx, dy), n.grad(ix + 1, iy, dx - 1, dy), u)
+  bottom := lerp(n.grad(ix, iy + 1, dx, dy - 1),
+                 n.grad(ix + 1, iy + 1, dx - 1, dy - 1), u)
+  // The gradients have a length of sqrt(2), so the raw range is about
+  // [-sqrt(2), sqrt(2)].
+  return lerp(top, bottom, v) / math.Sqrt2
+}
+
+// Random values at each lattice point, smoothly interpolated between them.
+// It is blockier than gradient noise.
+type ValueNoise struct {
+  perm *permutation
+}
+
+func CreateValueNoise(seed int64) *ValueNoise {
+  n := new(ValueNoise)
+  n.perm = createPermutation(seed)
+  return n
+}
+
+func (n *ValueNoise) value(ix, iy int) float64 {
+  return float64(n.perm.hash(ix, iy)) / float64(PERM_SIZE - 1) * 2 - 1
+}
+
+func (n *ValueNoise) Eval2(x, y float64) float64 {
+  x0 := math.Floor(x)
+  y0 := math.Floor(y)
+  ix, iy := int(x0), int(y0)
+  u, v := fade(x - x0), fade(y - y0)
+  top := lerp(n.value(ix, iy), n.value(ix + 1, iy), u)
+  bottom := lerp(n.value(ix, iy + 1), n.value(ix + 1, iy + 1), u)
+  return lerp(top, bottom, v)
+}
+
+// Cellular noise, with one feature point placed randomly within each lattice
+// cell. The value is highest, 1, on a feature point and falls to -1 one cell
+// away from the nearest one, which makes clusters around the points.
+type WorleyNoise struct {
+  perm *permutation
+}
+
+func CreateWorleyNoise(seed int64) *WorleyNoise {
+  n := new(WorleyNoise)
+  n.perm = createPermutation(seed)
+  return n
+}
+
+func (n *WorleyNoise) Eval2(x, y float64) float64 {
+  x0 := math.Floor(x)
+  y0 := math.Floor(y)
+  ix, iy := int(x0), int(y0)
+  nearest := math.Inf(1)
+  for cy := iy - 1; cy <= iy + 1; cy++ {
+    for cx := ix - 1; cx <= ix + 1; cx++ {
+      h := n.perm.hash(cx, cy)
+      px := float64(cx) + float64(h) / PERM_SIZE
+      py := float64(cy) + float64(n.perm.hash(cy, h)) / PERM_SIZE
+      dx, dy := px - x, py - y
+      nearest = math.Min(nearest, dx * dx + dy * dy)
+    }
+  }
+  return 1 - 2 * math.Min(1, math.Sqrt(nearest))
+}
+
+// Create the noise source named 'name'.
+func CreateNoise(name string, seed int64) (Noise2D, error) {
+  switch name {
+  case "simplex":
+    return opensimplex.New(seed), nil
+  case "perlin":
+    return CreatePerlinNoise(seed), nil
+  case "value":
+    return CreateValueNoise(seed), nil
+  case "worley":
+    return CreateWorleyNoise(seed), nil
+  }
+  return nil, newError(ErrBadParameter,
+                       "Invalid noise source '" + name +
+                       "', choose: simplex,perlin,value or worley", nil)
+}
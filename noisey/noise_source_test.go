@@ -0,0 +1,45 @@
+package noisey
+
+import "testing"
+
+// Each source gives the same noise for the same seed, different noise for
+// another seed, and stays within [-1, 1].
+func TestNoiseSources(t *testing.T) {
+  for _, name := range []string{ "perlin", "value", "worley" } {
+    a, err := CreateNoise(name, 7)
+    if err != nil {
+      t.Fatal(err)
+    }
+    b, _ := CreateNoise(name, 7)
+    other, _ := CreateNoise(name, 8)
+    differs := false
+    lowest, highest := 1.0, -1.0
+    for y := -20; y < 20; y++ {
+      for x := -20; x < 20; x++ {
+        fx, fy := float64(x) * 0.37, float64(y) * 0.29
+        v := a.Eval2(fx, fy)
+        if v != b.Eval2(fx, fy) {
+          t.Fatalf("%s: differs at %v,%v with the same seed", name, fx, fy)
+        }
+        if v < -1 || v > 1 {
+          t.Fatalf("%s: got %v at %v,%v", name, v, fx, fy)
+        }
+        differs = differs || v != other.Eval2(fx, fy)
+        if v < lowest {
+          lowest = v
+        }
+        if v > highest {
+          highest = v
+        }
+      }
+    }
+    if !differs {
+      t.Errorf("%s: the same noise for different seeds", name)
+    }
+    // The noise should cover a good part of its range, rather than being
+    // flat.
+    if highest - lowest < 1 {
+      t.Errorf("%s: only ranges from %v to %v", name, lowest, highest)
+    }
+  }
+}
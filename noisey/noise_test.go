@@ -0,0 +1,103 @@
+package noisey
+
+import (
+  "errors"
+  "math"
+  "testing"
+)
+
+// A fake noise source which returns the same value everywhere.
+type constNoise float64
+
+func (n constNoise) Eval2(x, y float64) float64 {
+  return float64(n)
+}
+
+// A fake noise source which returns its x coordinate, so that the frequency
+// of each octave shows up in the result.
+type rampNoise struct{}
+
+func (n rampNoise) Eval2(x, y float64) float64 {
+  return x
+}
+
+func fractal(t *testing.T, noise Noise2D, mode string, gain float64) *Fractal {
+  config := DefaultNoise(1)
+  config.Mode = mode
+  config.Gain = gain
+  f, err := CreateFractal(noise, config, false, false)
+  if err != nil {
+    t.Fatal(err)
+  }
+  return f
+}
+
+func TestFractal(t *testing.T) {
+  tests := []struct {
+    name string
+    noise Noise2D
+    mode string
+    gain float64
+    x, want float64
+  } {
+    // 0.5 * (1 + 0.5 + 0.25 + 0.125)
+    { "fbm", constNoise(0.5), "fbm", 1, 0, 0.9375 },
+    // 0.5 * (0.75 + 0.5 + 0.25 + 0.125)
+    { "fbm gain", constNoise(0.5), "fbm", 0.75, 0, 0.8125 },
+    // 0.75 * 0.1 + 0.5 * 0.2 + 0.25 * 0.4 + 0.125 * 0.8
+    { "fbm lacunarity", rampNoise{}, "fbm", 0.75, 0.1, 0.375 },
+    // Each octave is 2 * |-0.25| - 1 = -0.5, so -0.5 * 1.875.
+    { "billow", constNoise(-0.25), "billow", 1, 0, -0.9375 },
+    // The signals are 0.25, 0.125, 0.0625 and 0.03125, each weighted by the
+    // one before, which sum to 0.33203125 and are centred on 1.875 / 2.
+    { "ridged", constNoise(0.5), "ridged", 1, 0, -1.2109375 },
+  }
+  for _, test := range tests {
+    f := fractal(t, test.noise, test.mode, test.gain)
+    if got := f.Eval(test.x, 0); math.Abs(got - test.want) > 1e-12 {
+      t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+    }
+  }
+}
+
+func TestFractalAmplitude(t *testing.T) {
+  if got := fractal(t, constNoise(0), "fbm", 0.75).Amplitude(); got != 1.625 {
+    t.Errorf("got %v, want 1.625", got)
+  }
+  // Every octave at its largest stays within the amplitude.
+  f := fractal(t, constNoise(1), "fbm", 0.75)
+  if got := f.Eval(0, 0); got != f.Amplitude() {
+    t.Errorf("got %v, want %v", got, f.Amplitude())
+  }
+}
+
+// The layers are divided by the amplitude, so a fractal without one is
+// rejected.
+func TestFractalNoAmplitude(t *testing.T) {
+  tests := []struct {
+    octaves int
+    gain, persistence float64
+  } {
+    { 1, 0, 0.5 },
+    { 4, 0, 0 },
+    { 2, 1, math.Inf(1) },
+    { 2, math.NaN(), 0.5 },
+  }
+  for _, test := range tests {
+    config := DefaultNoise(1)
+    config.Octaves = test.octaves
+    config.Gain = test.gain
+    config.Persistence = test.persistence
+    _, err := CreateFractal(constNoise(0), config, false, false)
+    if !errors.Is(err, ErrBadParameter) {
+      t.Errorf("%d octaves, gain %v, persistence %v: got %v, want a bad " +
+               "parameter", test.octaves, test.gain, test.persistence, err)
+    }
+  }
+  config := DefaultNoise(1)
+  config.Gain = 0
+  if _, err := CreateFractal(constNoise(0), config, false, false);
+     err != nil {
+    t.Errorf("no gain with later octaves: got %v", err)
+  }
+}
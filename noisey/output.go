@@ -0,0 +1,126 @@
+package noisey
+
+import (
+  "fmt"
+  "hash/fnv"
+  "os"
+  "path/filepath"
+  "strconv"
+  "strings"
+  "time"
+)
+
+// The seeds which a world is generated from.
+type Seeds struct {
+  Height, Tree, Plant, Rock, Temperature, Soil int64
+}
+
+// Where the files from one run are written. Every file shares a prefix, made
+// by filling in the placeholders of a name template, and only the suffix
+// tells them apart.
+type Output struct {
+  dir, prefix string
+  seeds Seeds
+}
+
+// Seeds are always shown in hex, which is also how they are given on the
+// command line.
+func hexSeed(seed int64) string {
+  return strconv.FormatInt(seed, 16)
+}
+
+// A hash of the parameters which change the world, so that runs with the
+// same settings can be grouped together. How the world is drawn, where it is
+// written and how many threads generate it are left out, as are the seeds,
+// which have placeholders of their own.
+func configHash(c *Config) string {
+  h := fnv.New32a()
+  fmt.Fprintf(h, "%v %v %v %v %v\n", c.Width, c.Height, c.WrapX, c.WrapY,
+              c.WindDir)
+  fmt.Fprintf(h, "%+v %v %v %v %v\n", c.HeightNoise, c.HeightBaseline,
+              c.EdgeUp, c.EdgeDown, c.Falloff)
+  fmt.Fprintf(h, "%q %v %v %q\n", c.Shape, c.Islands, c.OpenEdge, c.Mask)
+  fmt.Fprintf(h, "%q %v %v %v\n", c.Heightmap, c.HeightmapMin,
+              c.HeightmapMax, c.HeightmapMix)
+  fmt.Fprintf(h, "%+v %+v %+v %v %v %v\n", c.TreeNoise, c.PlantNoise,
+              c.RockNoise, c.Placement, c.Spacing, c.LargeFeatures)
+  fmt.Fprintf(h, "%v %v %v %v\n", c.Water, c.Saturate, c.MaxClouds,
+              c.MergeClouds)
+  fmt.Fprintf(h, "%v %v %v %v %v\n", c.LatNorth, c.LatSouth, c.LapseRate,
+              c.TempNoise, c.TempFreq)
+  fmt.Fprintf(h, "%v %v %v\n", c.SFreq, c.SoilSlope, c.SoilHeight)
+  return fmt.Sprintf("%08x", h.Sum32())
+}
+
+// Fill in each {placeholder} in the template from 'values'.
+func expandTemplate(template string,
+                    values map[string]string) (string, error) {
+  var name strings.Builder
+  for {
+    begin := strings.IndexByte(template, '{')
+    if begin == -1 {
+      break
+    }
+    end := strings.IndexByte(template[begin:], '}')
+    if end == -1 {
+      return "", newError(ErrBadParameter, "unclosed placeholder in name '" +
+                          template + "'", nil)
+    }
+    key := template[begin + 1 : begin + end]
+    value, ok := values[key]
+    if !ok {
+      return "", newError(ErrBadParameter, "unknown placeholder {" + key +
+                          "}, choose: hseed,tseed,pseed,rseed,teseed,sseed," +
+                          "width,height,time or hash", nil)
+    }
+    name.WriteString(template[:begin])
+    name.WriteString(value)
+    template = template[begin + end + 1:]
+  }
+  name.WriteString(template)
+  if name.Len() == 0 || strings.ContainsRune(name.String(), os.PathSeparator) {
+    return "", newError(ErrBadParameter, "invalid name '" + name.String() +
+                        "'", nil)
+  }
+  return name.String(), nil
+}
+
+// Create the output directory, if needed, and the prefix for the files of a
+// run, from the config's name template. The seeds are written in hex.
+func CreateOutput(config *Config, start time.Time) (*Output, error) {
+  seeds := config.Seeds
+  values := map[string]string {
+    "hseed": hexSeed(seeds.Height),
+    "tseed": hexSeed(seeds.Tree),
+    "pseed": hexSeed(seeds.Plant),
+    "rseed": hexSeed(seeds.Rock),
+    "teseed": hexSeed(seeds.Temperature),
+    "sseed": hexSeed(seeds.Soil),
+    "width": strconv.Itoa(config.Width),
+    "height": strconv.Itoa(config.Height),
+    "time": start.Format("20060102-150405"),
+    "hash": configHash(config),
+  }
+  prefix, err := expandTemplate(config.Name, values)
+  if err != nil {
+    return nil, err
+  }
+  if err := os.MkdirAll(config.OutDir, 0755); err != nil {
+    return nil, newError(ErrEncode, config.OutDir, err)
+  }
+  output := new(Output)
+  output.dir = config.OutDir
+  output.prefix = prefix
+  output.seeds = seeds
+  return output, nil
+}
+
+// The name of one of the run's files, such as "-world.json".
+func (o *Output) Name(suffix string) string {
+  return o.prefix + suffix
+}
+
+// The path to one of the run's files.
+func (o *Output) Path(suffix string) string {
+  return filepath.Join(o.dir, o.Name(suffix))
+}
@@ -0,0 +1,83 @@
+package noisey
+
+import (
+  "errors"
+  "testing"
+)
+
+func TestExpandTemplate(t *testing.T) {
+  values := map[string]string {
+    "hseed": "2a",
+    "width": "32",
+    "empty": "",
+  }
+  tests := []struct {
+    template, want string
+    err bool
+  } {
+    { "world", "world", false },
+    { "h{hseed}-w{width}", "h2a-w32", false },
+    { "{hseed}{hseed}", "2a2a", false },
+    { "a}b", "a}b", false },
+    { "a{empty}", "a", false },
+    // The placeholder runs to the first closing brace, so a nested one is
+    // part of its key.
+    { "{{hseed}}", "", true },
+    { "h{hseed", "", true },
+    { "h{hseed-{width", "", true },
+    { "{seed}", "", true },
+    { "{}", "", true },
+    { "", "", true },
+    { "{empty}", "", true },
+    { "runs/{hseed}", "", true },
+  }
+  for _, test := range tests {
+    got, err := expandTemplate(test.template, values)
+    if test.err {
+      if !errors.Is(err, ErrBadParameter) {
+        t.Errorf("%q: got %q and error %v, want a bad parameter",
+                 test.template, got, err)
+      }
+    } else if err != nil || got != test.want {
+      t.Errorf("%q: got %q and error %v, want %q", test.template, got, err,
+               test.want)
+    }
+  }
+}
+
+// Only the parameters which change the world change the hash.
+func TestConfigHash(t *testing.T) {
+  base, err := ParseConfig([]string{ "-hSeed", "1" })
+  if err != nil {
+    t.Fatal(err)
+  }
+  hash := configHash(base)
+  tests := []struct {
+    name string
+    change func(c *Config)
+    same bool
+  } {
+    { "seeds", func(c *Config) { c.Seeds.Height++ }, true },
+    { "threads", func(c *Config) { c.Threads = 4 }, true },
+    { "output", func(c *Config) { c.OutDir, c.Name = "runs", "run" }, true },
+    { "bands", func(c *Config) { c.Band, c.Split = 8, 16 }, true },
+    { "drawing", func(c *Config) {
+        c.Scale, c.Iso, c.Animate, c.Hillshade = 2, true, GIF_ANIMATION, 1
+      }, true },
+    { "poster", func(c *Config) { c.Poster, c.PosterScale = true, 2 }, true },
+    { "resources", func(c *Config) { c.Resources = "art" }, true },
+    { "width", func(c *Config) { c.Width++ }, false },
+    { "noise", func(c *Config) { c.HeightNoise.Octaves++ }, false },
+    { "shape", func(c *Config) { c.Shape = "radial" }, false },
+    { "water", func(c *Config) { c.Water++ }, false },
+    { "soil", func(c *Config) { c.SoilSlope++ }, false },
+  }
+  for _, test := range tests {
+    c := *base
+    test.change(&c)
+    if got := configHash(&c); (got == hash) != test.same {
+      t.Errorf("%s: got %s from %s, want the same hash: %v", test.name, got,
+               hash, test.same)
+    }
+  }
+}
@@ -0,0 +1,61 @@
+package noisey
+
+// A column of the map, from xBegin up to, but not including, xEnd.
+type Strip struct {
+  xBegin, xEnd int
+}
+
+type job struct {
+  strip Strip
+  work func(xBegin, xEnd int, c chan int)
+}
+
+// A queue of strips, each one region wide, which are shared out between a
+// fixed number of goroutines. Because the strips follow the region
+// boundaries, no region is ever split between two goroutines, and any width
+// can be divided up, with the last strip just holding a partial region.
+type WorkQueue struct {
+  strips []Strip
+  threads int
+}
+
+func CreateWorkQueue(width, threads int) *WorkQueue {
+  q := new(WorkQueue)
+  q.threads = threads
+  q.strips = make([]Strip, 0, (width + REGION_SIZE - 1) / REGION_SIZE)
+  for x := 0; x < width; x += REGION_SIZE {
+    xEnd := x + REGION_SIZE
+    if xEnd > width {
+      xEnd = width
+    }
+    q.strips = append(q.strips, Strip{ x, xEnd })
+  }
+  return q
+}
+
+// Run every function over every strip and wait for them all to finish. Each
+// function signals on its channel once it has finished with a strip.
+func (q *WorkQueue) Run(work ...func(xBegin, xEnd int, c chan int)) {
+  jobs := make(chan job, len(q.strips) * len(work))
+  for _, strip := range q.strips {
+    for _, w := range work {
+      jobs <- job{ strip, w }
+    }
+  }
+  close(jobs)
+
+  c := make(chan int, q.threads)
+  for i := 0; i < q.threads; i++ {
+    go func() {
+      done := make(chan int, 1)
+      for j := range jobs {
+        j.work(j.strip.xBegin, j.strip.xEnd, done)
+        <-done
+      }
+      c <- 1
+    }()
+  }
+  for i := 0; i < q.threads; i++ {
+    <-c
+  }
+}
@@ -0,0 +1,168 @@
+package noisey
+
+import (
+  "fmt"
+  "math"
+  "math/rand"
+)
+
+// Ways of choosing where trees, rocks and plants go.
+const (
+  // The locations with the highest noise values in each region.
+  REGION_PLACEMENT = iota
+  // Poisson-disk sampling, keeping a minimum distance between features.
+  POISSON_PLACEMENT
+  NUM_PLACEMENTS
+)
+
+var PLACEMENT_NAMES = [NUM_PLACEMENTS]string {
+  "region",
+  "poisson",
+}
+
+// Features become less likely within this many locations of water or a wall.
+const SHORE_FALLOFF = 2
+
+// One kind of feature to be scattered across the world.
+type Scatter struct {
+  name string
+  feature uint
+  density *[BIOMES]int
+  soilWeight float64
+  // The noise value at a location, or false if it can't hold the feature.
+  value func(loc *Location) (float64, bool)
+  // The largest magnitude of the feature's noise.
+  noiseRange func(w *World) float64
+}
+
+var SCATTERS = []Scatter {
+  { "trees", TREE_FEATURE, &TREE_DENSITY, 0.5,
+    func(loc *Location) (float64, bool) {
+      if loc.biome == BEACH || loc.soil <= NO_SOIL {
+        return 0, false
+      }
+      return loc.tree + SOIL_BIAS * loc.soil, true
+    },
+    func(w *World) float64 { return w.treeRange } },
+  { "rocks", ROCK_FEATURE, &ROCK_DENSITY, -0.5,
+    func(loc *Location) (float64, bool) {
+      return loc.rock - SOIL_BIAS * loc.soil, true
+    },
+    func(w *World) float64 { return w.rockRange } },
+  { "plants", PLANT_FEATURE, &PLANT_DENSITY, 0.25,
+    func(loc *Location) (float64, bool) {
+      if loc.isRiverBank {
+        return 0, false
+      }
+      return loc.plant, true
+    },
+    func(w *World) float64 { return w.plantRange } },
+}
+
+func isObstacle(loc *Location) bool {
+  return loc.biome == OCEAN || loc.isRiver || loc.isWall
+}
+
+// Find how far each location is from the nearest water or wall, measured in
+// steps to any of the eight neighbours and capped at SHORE_FALLOFF + 1.
+func (w *World) shoreDistance() []int {
+  dist := make([]int, len(w.locations))
+  frontier := make([]*Location, 0, len(w.locations))
+  for i := range w.locations {
+    dist[i] = SHORE_FALLOFF + 1
+    if isObstacle(&w.locations[i]) {
+      dist[i] = 0
+      frontier = append(frontier, &w.locations[i])
+    }
+  }
+  for d := 1; d <= SHORE_FALLOFF; d++ {
+    next := make([]*Location, 0, len(frontier))
+    for _, loc := range frontier {
+      for dir := uint(0); dir < MAX_DIR; dir++ {
+        n := w.getDirectedLocation(loc, dir)
+        if n == nil || dist[n.y * w.width + n.x] <= d {
+          continue
+        }
+        dist[n.y * w.width + n.x] = d
+        next = append(next, n)
+      }
+    }
+    frontier = next
+  }
+  return dist
+}
+
+// Whether the feature is already present within 'radius' of loc.
+func (w *World) featureWithin(loc *Location, feature uint, radius float64) bool {
+  r := int(math.Ceil(radius))
+  for dy := -r; dy <= r; dy++ {
+    for dx := -r; dx <= r; dx++ {
+      if float64(dx * dx + dy * dy) > radius * radius {
+        continue
+      }
+      if n := w.WrappedLocation(loc.x + dx, loc.y + dy);
+         n != nil && n.hasFeature(feature) {
+        return true
+      }
+    }
+  }
+  return false
+}
+
+// The minimum distance between features, for 'density' features per region
+// scaled by 'local'. Randomly packed disks fill about 70% of the space, so
+// the spacing is a little under the average distance between features. Where
+// the density is close to none, the spacing is capped at the width of a
+// region, which keeps the search for nearby features small.
+func featureSpacing(spacing float64, density int, local float64) float64 {
+  radius := spacing * math.Sqrt(float64(REGION_AREA) /
+                                (float64(density) * local))
+  return math.Min(radius, spacing * REGION_SIZE)
+}
+
+// Scatter trees, then rocks, then plants with Poisson-disk sampling. Every
+// location is visited in a random order and given a feature if none of the
+// same kind lies within its minimum spacing. The spacing comes from the
+// density of the location's biome, scaled by 'spacing', and shrinks where the
+// feature's noise layer and the soil depth favour it. Locations close to
+// water or a wall are then only accepted some of the time.
+func (w *World) PlaceFeatures(spacing float64, seed int64) {
+  rng := rand.New(rand.NewSource(seed))
+  shore := w.shoreDistance()
+  order := rng.Perm(len(w.locations))
+
+  for _, scatter := range SCATTERS {
+    noiseRange := scatter.noiseRange(w)
+    count := 0
+    for _, i := range order {
+      loc := &w.locations[i]
+      density := scatter.density[loc.biome]
+      if density == 0 || isObstacle(loc) ||
+         loc.hasFeature(TREE_FEATURE) || loc.hasFeature(ROCK_FEATURE) ||
+         loc.hasFeature(PLANT_FEATURE) {
+        continue
+      }
+      value, ok := scatter.value(loc)
+      if !ok {
+        continue
+      }
+      // The noise gives between none and twice the biome's density, which
+      // the soil can double again.
+      local := math.Max(0, math.Min(2, value / noiseRange + 1))
+      local *= soilScale(loc.soil, scatter.soilWeight)
+      if local == 0 {
+        continue
+      }
+      if rng.Float64() >= float64(shore[i]) / (SHORE_FALLOFF + 1) {
+        continue
+      }
+      radius := featureSpacing(spacing, density, local)
+      if w.featureWithin(loc, scatter.feature, radius) {
+        continue
+      }
+      loc.addFeature(scatter.feature)
+      count++
+    }
+    fmt.Println("Scattered", count, scatter.name)
+  }
+}
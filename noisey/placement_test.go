@@ -0,0 +1,26 @@
+package noisey
+
+import "testing"
+
+func TestFeatureSpacing(t *testing.T) {
+  tests := []struct {
+    spacing float64
+    density int
+    local, want float64
+  } {
+    // One feature per region is spaced a region apart.
+    { 1, 1, 1, REGION_SIZE },
+    { 0.5, 4, 1, REGION_SIZE / 4 },
+    { 1, 4, 4, REGION_SIZE / 4 },
+    // Sparser than one feature per region is capped.
+    { 1, 1, 0.25, REGION_SIZE },
+    { 0.8, 2, 1e-6, 0.8 * REGION_SIZE },
+  }
+  for _, test := range tests {
+    got := featureSpacing(test.spacing, test.density, test.local)
+    if got != test.want {
+      t.Errorf("spacing %v, density %d, local %v: got %v, want %v",
+               test.spacing, test.density, test.local, got, test.want)
+    }
+  }
+}
@@ -0,0 +1,160 @@
+package noisey
+
+import (
+  "bufio"
+  "compress/zlib"
+  "encoding/binary"
+  "hash/crc32"
+  "image"
+  "image/draw"
+  "io"
+)
+
+// Image data is split into chunks of at most this many bytes.
+const PNG_CHUNK_SIZE = 1 << 16
+
+var PNG_HEADER = []byte("\x89PNG\r\n\x1a\n")
+
+// Writes a png a band of rows at a time, so that the whole image never has
+// to be held in memory. Pixels are stored as 8-bit RGBA and each row uses
+// the Sub filter, which suits flat areas of colour.
+type PNGStream struct {
+  w *bufio.Writer
+  width, height, rows int
+  zw *zlib.Writer
+  idat []byte
+  line, row []byte
+  err error
+}
+
+func (s *PNGStream) writeChunk(name string, data []byte) {
+  if s.err != nil {
+    return
+  }
+  var header [8]byte
+  binary.BigEndian.PutUint32(header[:4], uint32(len(data)))
+  copy(header[4:], name)
+  crc := crc32.NewIEEE()
+  crc.Write(header[4:])
+  crc.Write(data)
+  var footer [4]byte
+  binary.BigEndian.PutUint32(footer[:], crc.Sum32())
+  for _, b := range [][]byte{ header[:], data, footer[:] } {
+    if _, s.err = s.w.Write(b); s.err != nil {
+      return
+    }
+  }
+}
+
+// Collects the compressed data, writing it out in IDAT chunks.
+func (s *PNGStream) Write(data []byte) (int, error) {
+  n := len(data)
+  for len(data) > 0 {
+    space := PNG_CHUNK_SIZE - len(s.idat)
+    if space > len(data) {
+      space = len(data)
+    }
+    s.idat = append(s.idat, data[:space]...)
+    data = data[space:]
+    if len(s.idat) == PNG_CHUNK_SIZE {
+      s.writeChunk("IDAT", s.idat)
+      s.idat = s.idat[:0]
+    }
+  }
+  return n, s.err
+}
+
+// Start a png of width x height pixels.
+func CreatePNGStream(w io.Writer, width, height int) (*PNGStream, error) {
+  s := new(PNGStream)
+  s.w = bufio.NewWriter(w)
+  s.width = width
+  s.height = height
+  s.idat = make([]byte, 0, PNG_CHUNK_SIZE)
+  s.line = make([]byte, 1 + width * 4)
+  s.row = make([]byte, width * 4)
+  if _, err := s.w.Write(PNG_HEADER); err != nil {
+    return nil, newError(ErrEncode, "png", err)
+  }
+  var ihdr [13]byte
+  binary.BigEndian.PutUint32(ihdr[0:4], uint32(width))
+  binary.BigEndian.PutUint32(ihdr[4:8], uint32(height))
+  ihdr[8] = 8 // bits per channel
+  ihdr[9] = 6 // RGBA
+  s.writeChunk("IHDR", ihdr[:])
+  var err error
+  if s.zw, err = zlib.NewWriterLevel(s, zlib.BestSpeed); err != nil {
+    return nil, newError(ErrEncode, "png", err)
+  }
+  if s.err != nil {
+    return nil, newError(ErrEncode, "png", s.err)
+  }
+  return s, nil
+}
+
+// Append every row of the image, which has to be as wide as the png.
+func (s *PNGStream) WriteRows(img image.Image) error {
+  bounds := img.Bounds()
+  if bounds.Dx() != s.width {
+    return newError(ErrEncode, "rows don't match the width of the png", nil)
+  }
+  rgba, ok := img.(*image.RGBA)
+  if !ok {
+    rgba = image.NewRGBA(bounds)
+    draw.Draw(rgba, bounds, img, bounds.Min, draw.Src)
+  }
+  for y := bounds.Min.Y; y < bounds.Max.Y && s.err == nil; y++ {
+    if s.rows == s.height {
+      return newError(ErrEncode, "too many rows for the png", nil)
+    }
+    pix := rgba.Pix[rgba.PixOffset(bounds.Min.X, y):][:s.width * 4]
+    // Convert from premultiplied alpha, as the png stores straight alpha,
+    // rounding the same way as color.NRGBAModel so that the pixels match
+    // those written by image/png.
+    copy(s.row, pix)
+    for i := 0; i < len(s.row); i += 4 {
+      if a := uint32(s.row[i + 3]); a != 0xff && a != 0 {
+        for c := 0; c < 3; c++ {
+          s.row[i + c] = uint8(uint32(s.row[i + c]) * 0xffff / a >> 8)
+        }
+      }
+    }
+    s.line[0] = 1
+    for i, v := range s.row {
+      if i < 4 {
+        s.line[1 + i] = v
+      } else {
+        s.line[1 + i] = v - s.row[i - 4]
+      }
+    }
+    if _, err := s.zw.Write(s.line); err != nil {
+      return newError(ErrEncode, "png", err)
+    }
+    s.rows++
+  }
+  if s.err != nil {
+    return newError(ErrEncode, "png", s.err)
+  }
+  return nil
+}
+
+// Finish the png, which has to have had all of its rows written.
+func (s *PNGStream) Close() error {
+  if s.rows != s.height {
+    return newError(ErrEncode, "png is missing rows", nil)
+  }
+  if err := s.zw.Close(); err != nil {
+    return newError(ErrEncode, "png", err)
+  }
+  if len(s.idat) > 0 {
+    s.writeChunk("IDAT", s.idat)
+  }
+  s.writeChunk("IEND", nil)
+  if s.err == nil {
+    s.err = s.w.Flush()
+  }
+  if s.err != nil {
+    return newError(ErrEncode, "png", s.err)
+  }
+  return nil
+}
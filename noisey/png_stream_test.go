@@ -0,0 +1,166 @@
+package noisey
+
+import (
+  "bytes"
+  "errors"
+  "image"
+  "image/color"
+  "image/png"
+  "math/rand"
+  "os"
+  "path/filepath"
+  "strconv"
+  "testing"
+)
+
+// Check that two images are the same size and have the same pixels.
+func compareImages(t *testing.T, name string, got, want image.Image) {
+  if got.Bounds().Size() != want.Bounds().Size() {
+    t.Fatalf("%s: got a size of %v, want %v", name, got.Bounds().Size(),
+             want.Bounds().Size())
+  }
+  diff := 0
+  size := want.Bounds().Size()
+  for y := 0; y < size.Y; y++ {
+    for x := 0; x < size.X; x++ {
+      g := color.NRGBAModel.Convert(got.At(got.Bounds().Min.X + x,
+                                           got.Bounds().Min.Y + y))
+      w := color.NRGBAModel.Convert(want.At(want.Bounds().Min.X + x,
+                                            want.Bounds().Min.Y + y))
+      if g != w {
+        if diff == 0 {
+          t.Errorf("%s: at %d,%d got %v, want %v", name, x, y, g, w)
+        }
+        diff++
+      }
+    }
+  }
+  if diff > 0 {
+    t.Errorf("%s: %d pixels differ", name, diff)
+  }
+}
+
+func decodePNG(t *testing.T, data []byte) image.Image {
+  img, err := png.Decode(bytes.NewReader(data))
+  if err != nil {
+    t.Fatal(err)
+  }
+  return img
+}
+
+// A png streamed in bands of rows decodes to the same pixels as one encoded
+// by image/png, including translucent pixels. The noise doesn't compress, so
+// the data is also split over several chunks.
+func TestPNGStream(t *testing.T) {
+  rng := rand.New(rand.NewSource(1))
+  img := image.NewRGBA(image.Rect(0, 0, 160, 120))
+  for i := 0; i < len(img.Pix); i += 4 {
+    a := rng.Intn(256)
+    switch rng.Intn(4) {
+    case 0:
+      a = 0
+    case 1:
+      a = 0xff
+    }
+    for c := 0; c < 3; c++ {
+      img.Pix[i + c] = uint8(rng.Intn(a + 1))
+    }
+    img.Pix[i + 3] = uint8(a)
+  }
+  var want bytes.Buffer
+  if err := png.Encode(&want, img); err != nil {
+    t.Fatal(err)
+  }
+
+  var got bytes.Buffer
+  stream, err := CreatePNGStream(&got, 160, 120)
+  if err != nil {
+    t.Fatal(err)
+  }
+  bands := []int{ 0, 1, 50, 51, 120 }
+  for i := 1; i < len(bands); i++ {
+    rows := img.SubImage(image.Rect(0, bands[i - 1], 160, bands[i]))
+    if err := stream.WriteRows(rows); err != nil {
+      t.Fatal(err)
+    }
+  }
+  if err := stream.Close(); err != nil {
+    t.Fatal(err)
+  }
+  if got.Len() <= PNG_CHUNK_SIZE {
+    t.Errorf("the image data fits in one chunk")
+  }
+  compareImages(t, "stream", decodePNG(t, got.Bytes()),
+                decodePNG(t, want.Bytes()))
+}
+
+func TestPNGStreamRows(t *testing.T) {
+  var buf bytes.Buffer
+  stream, err := CreatePNGStream(&buf, 4, 2)
+  if err != nil {
+    t.Fatal(err)
+  }
+  err = stream.WriteRows(image.NewRGBA(image.Rect(0, 0, 3, 1)))
+  if !errors.Is(err, ErrEncode) {
+    t.Errorf("narrow rows: got %v, want an encode failure", err)
+  }
+  if err := stream.WriteRows(image.NewRGBA(image.Rect(0, 0, 4, 1)));
+     err != nil {
+    t.Fatal(err)
+  }
+  if err := stream.Close(); !errors.Is(err, ErrEncode) {
+    t.Errorf("missing rows: got %v, want an encode failure", err)
+  }
+  err = stream.WriteRows(image.NewRGBA(image.Rect(0, 0, 4, 2)))
+  if !errors.Is(err, ErrEncode) {
+    t.Errorf("extra rows: got %v, want an encode failure", err)
+  }
+}
+
+// The detailed map is the same whether it's streamed in bands of any height
+// or saved split into tiles.
+func TestBandedAndSplitRenders(t *testing.T) {
+  const width, height, split, scale = 24, 16, 10, 2
+  draw := func(args ...string) string {
+    dir := t.TempDir()
+    args = append(args, "-width", strconv.Itoa(width),
+                  "-height", strconv.Itoa(height),
+                  "-scale", strconv.Itoa(scale), "-hSeed", "2a",
+                  "-tSeed", "2b", "-pSeed", "2c", "-rSeed", "2d",
+                  "-teSeed", "2e", "-sSeed", "2f", "-name", "run",
+                  "-outdir", dir)
+    config, err := ParseConfig(args)
+    if err != nil {
+      t.Fatal(err)
+    }
+    if err := GenerateMap(config); err != nil {
+      t.Fatal(err)
+    }
+    return dir
+  }
+  load := func(filename string) image.Image {
+    data, err := os.ReadFile(filename)
+    if err != nil {
+      t.Fatal(err)
+    }
+    return decodePNG(t, data)
+  }
+
+  whole := load(filepath.Join(draw("-band", "32"), "run-map.png"))
+  banded := load(filepath.Join(draw("-band", "3"), "run-map.png"))
+  compareImages(t, "banded", banded, whole)
+
+  dir := draw("-split", strconv.Itoa(split))
+  size := whole.Bounds().Dx() / width * split
+  for y := 0; y * split < height; y++ {
+    for x := 0; x * split < width; x++ {
+      name := "run-map-" + strconv.Itoa(x) + "-" + strconv.Itoa(y) + ".png"
+      area := image.Rect(x * size, y * size, (x + 1) * size, (y + 1) * size)
+      area = area.Intersect(whole.Bounds())
+      part := whole.(interface {
+        SubImage(r image.Rectangle) image.Image
+      }).SubImage(area)
+      compareImages(t, name, load(filepath.Join(dir, name)), part)
+    }
+  }
+}
@@ -0,0 +1,408 @@
+package noisey
+
+import (
+  "fmt"
+  "image"
+  "image/color"
+  "image/draw"
+  "image/png"
+  "math"
+  "math/rand"
+  "sort"
+  "strconv"
+  "strings"
+)
+
+// The layout of the poster, in pixels.
+const POSTER_MARGIN = 16
+const POSTER_TEXT = 2
+const POSTER_LINE = (FONT_HEIGHT + 3) * POSTER_TEXT
+const POSTER_SMALL_LINE = FONT_HEIGHT + 4
+const POSTER_SWATCH = 14
+const COMPASS_SIZE = 112
+const SCALE_BAR_HEIGHT = 8
+
+// The smallest island, and the shortest river, which are named.
+const MIN_ISLAND_AREA = 16
+const MIN_RIVER_LENGTH = 8
+
+var POSTER_PAPER = color.RGBA{ 240, 232, 208, 255 }
+var POSTER_INK = color.RGBA{ 40, 32, 24, 255 }
+var POSTER_WIND = color.RGBA{ 40, 90, 200, 255 }
+var POSTER_LABEL = color.RGBA{ 255, 255, 255, 255 }
+
+// Pieces which are put together to make up place names.
+var NAME_ONSETS = []string{ "b", "br", "c", "d", "dr", "f", "g", "gl", "h",
+                            "k", "l", "m", "n", "p", "r", "s", "st", "t",
+                            "th", "v", "w" }
+var NAME_VOWELS = []string{ "a", "e", "i", "o", "u", "ai", "ea", "ou", "y" }
+var NAME_CODAS = []string{ "", "n", "r", "s", "th", "ll", "ck", "m", "nd" }
+
+// A named place, and where to put its label.
+type PlaceLabel struct {
+  x, y int
+  name string
+  peak bool
+}
+
+// Make up a name of two or three syllables.
+func placeName(rng *rand.Rand) string {
+  var name strings.Builder
+  syllables := 2 + rng.Intn(2)
+  for i := 0; i < syllables; i++ {
+    name.WriteString(NAME_ONSETS[rng.Intn(len(NAME_ONSETS))])
+    name.WriteString(NAME_VOWELS[rng.Intn(len(NAME_VOWELS))])
+  }
+  name.WriteString(NAME_CODAS[rng.Intn(len(NAME_CODAS))])
+  return strings.ToUpper(name.String()[:1]) + name.String()[1:]
+}
+
+// Find the groups of neighbouring locations, which don't wrap, that are all
+// members. Groups are found in scan order.
+func findComponents(w *World, member func(loc *Location) bool) [][]*Location {
+  visited := make([]bool, w.width * w.height)
+  components := make([][]*Location, 0)
+  for i := range w.locations {
+    if visited[i] || !member(&w.locations[i]) {
+      continue
+    }
+    visited[i] = true
+    component := make([]*Location, 0)
+    stack := []*Location{ &w.locations[i] }
+    for len(stack) != 0 {
+      loc := stack[len(stack) - 1]
+      stack = stack[:len(stack) - 1]
+      component = append(component, loc)
+      for dir := NORTH; dir < MAX_DIR; dir += 2 {
+        x := loc.x + DIR_DELTA_X[dir]
+        y := loc.y + DIR_DELTA_Y[dir]
+        if x < 0 || y < 0 || x >= w.width || y >= w.height {
+          continue
+        }
+        idx := y * w.width + x
+        if !visited[idx] && member(&w.locations[idx]) {
+          visited[idx] = true
+          stack = append(stack, &w.locations[idx])
+        }
+      }
+    }
+    components = append(components, component)
+  }
+  return components
+}
+
+// The member of the component closest to its middle.
+func componentCentre(component []*Location) *Location {
+  cx, cy := 0.0, 0.0
+  for _, loc := range component {
+    cx += float64(loc.x)
+    cy += float64(loc.y)
+  }
+  cx /= float64(len(component))
+  cy /= float64(len(component))
+  closest := component[0]
+  best := math.Inf(1)
+  for _, loc := range component {
+    dx := float64(loc.x) - cx
+    dy := float64(loc.y) - cy
+    if d := dx * dx + dy * dy; d < best {
+      best = d
+      closest = loc
+    }
+  }
+  return closest
+}
+
+// Name the larger islands, their peaks, if they reach the midlands, and the
+// longer rivers, in the order their labels should be placed.
+func FindPlaces(w *World, seed int64) []PlaceLabel {
+  rng := rand.New(rand.NewSource(seed))
+  places := make([]PlaceLabel, 0)
+  peaks := make([]PlaceLabel, 0)
+  islands := findComponents(w, func(loc *Location) bool {
+    return loc.biome != OCEAN
+  })
+  sort.SliceStable(islands, func(i, j int) bool {
+    return len(islands[i]) > len(islands[j])
+  })
+  for _, island := range islands {
+    if len(island) < MIN_ISLAND_AREA {
+      break
+    }
+    centre := componentCentre(island)
+    places = append(places, PlaceLabel{ centre.x, centre.y,
+                                        placeName(rng) + " Isle", false })
+    peak := island[0]
+    for _, loc := range island {
+      if loc.height > peak.height {
+        peak = loc
+      }
+    }
+    if peak.terrace >= 3 {
+      peaks = append(peaks, PlaceLabel{ peak.x, peak.y,
+                                        "Mount " + placeName(rng), true })
+    }
+  }
+  places = append(places, peaks...)
+  rivers := findComponents(w, func(loc *Location) bool {
+    return loc.isRiver
+  })
+  for _, river := range rivers {
+    if len(river) < MIN_RIVER_LENGTH {
+      continue
+    }
+    centre := componentCentre(river)
+    places = append(places, PlaceLabel{ centre.x, centre.y,
+                                        "River " + placeName(rng), false })
+  }
+  return places
+}
+
+// Draw a line 'width' pixels thick.
+func drawLine(img draw.Image, x0, y0, x1, y1, width int, c color.Color) {
+  src := &image.Uniform{ c }
+  dx := float64(x1 - x0)
+  dy := float64(y1 - y0)
+  steps := int(math.Max(math.Abs(dx), math.Abs(dy)))
+  if steps == 0 {
+    steps = 1
+  }
+  for i := 0; i <= steps; i++ {
+    x := x0 + int(math.Round(dx * float64(i) / float64(steps))) - width / 2
+    y := y0 + int(math.Round(dy * float64(i) / float64(steps))) - width / 2
+    draw.Draw(img, image.Rect(x, y, x + width, y + width), src, image.Point{},
+              draw.Src)
+  }
+}
+
+// Draw an arrow from x0, y0 with its head at x1, y1.
+func drawArrow(img draw.Image, x0, y0, x1, y1, width, head int,
+               c color.Color) {
+  drawLine(img, x0, y0, x1, y1, width, c)
+  angle := math.Atan2(float64(y1 - y0), float64(x1 - x0))
+  for _, side := range []float64{ -1, 1 } {
+    a := angle + math.Pi + side * math.Pi / 6
+    drawLine(img, x1, y1, x1 + int(math.Round(math.Cos(a) * float64(head))),
+             y1 + int(math.Round(math.Sin(a) * float64(head))), width, c)
+  }
+}
+
+// Draw a compass rose with its centre at cx, cy, and an arrow showing which
+// way the wind blew the clouds.
+func drawCompass(img draw.Image, cx, cy int, windDir uint) {
+  r := COMPASS_SIZE / 2 - FONT_HEIGHT * POSTER_TEXT - 4
+  drawLine(img, cx, cy + r, cx, cy - r, 1, POSTER_INK)
+  drawLine(img, cx - r, cy, cx + r, cy, 1, POSTER_INK)
+  drawArrow(img, cx, cy, cx, cy - r, 2, 8, POSTER_INK)
+  letters := []struct {
+    text string
+    dx, dy int
+  } {
+    { "N", 0, -1 }, { "E", 1, 0 }, { "S", 0, 1 }, { "W", -1, 0 },
+  }
+  w := TextWidth("N", POSTER_TEXT)
+  h := FONT_HEIGHT * POSTER_TEXT
+  for _, l := range letters {
+    x := cx + l.dx * (r + 4 + w / 2) - w / 2
+    y := cy + l.dy * (r + 4 + h / 2) - h / 2
+    DrawText(img, x, y, l.text, POSTER_TEXT, POSTER_INK)
+  }
+  length := float64(r) * 0.7
+  dx := float64(DIR_DELTA_X[windDir])
+  dy := float64(DIR_DELTA_Y[windDir])
+  drawArrow(img, cx - int(dx * length), cy - int(dy * length),
+            cx + int(dx * length), cy + int(dy * length), 3, 10, POSTER_WIND)
+}
+
+// The longest round length, of 1, 2 or 5 times a power of ten, which is no
+// more than 'most'.
+func scaleBarLength(most int) int {
+  length := 1
+  for power := 1; power <= most; power *= 10 {
+    for _, step := range []int{ 1, 2, 5 } {
+      if step * power <= most {
+        length = step * power
+      }
+    }
+  }
+  return length
+}
+
+// Draw a bar, starting at x, y, marking out 'tiles' tiles of 'scale' pixels,
+// with its length written after it.
+func drawScaleBar(img draw.Image, x, y, tiles, scale int) {
+  const SEGMENTS = 4
+  width := tiles * scale
+  for i := 0; i < SEGMENTS; i++ {
+    x0 := x + width * i / SEGMENTS
+    x1 := x + width * (i + 1) / SEGMENTS
+    r := image.Rect(x0, y, x1, y + SCALE_BAR_HEIGHT)
+    draw.Draw(img, r, &image.Uniform{ POSTER_INK }, image.Point{}, draw.Src)
+    if i % 2 == 1 {
+      draw.Draw(img, r.Inset(1), &image.Uniform{ POSTER_PAPER }, image.Point{},
+                draw.Src)
+    }
+  }
+  label := strconv.Itoa(tiles) + " tiles"
+  if tiles == 1 {
+    label = "1 tile"
+  }
+  DrawText(img, x + width + 8,
+           y + (SCALE_BAR_HEIGHT - FONT_HEIGHT * POSTER_TEXT) / 2, label,
+           POSTER_TEXT, POSTER_INK)
+}
+
+// A row of the legend.
+type legendEntry struct {
+  colour color.RGBA
+  name string
+}
+
+// Draw the overworld, scaled up by 'scale', as a poster with a legend of the
+// biomes on the map, a scale bar, a compass showing the wind and the seeds.
+// The overworld is drawn unlit, so that its colours match the legend. With
+// 'labels', the larger islands, their peaks and the longer rivers are
+// named on the map.
+func DrawPoster(w *World, scale int, labels bool, output *Output) error {
+  // Only list what's on the map.
+  var present [BIOMES]bool
+  trees, rocks := false, false
+  for y := 0; y < w.height; y++ {
+    for x := 0; x < w.width; x++ {
+      loc := w.Location(x, y)
+      present[w.Biome(x, y)] = true
+      trees = trees || loc.hasFeature(TREE_FEATURE)
+      rocks = rocks || loc.hasFeature(ROCK_FEATURE)
+    }
+  }
+  legend := make([]legendEntry, 0, BIOMES + 2)
+  for biome, colour := range BIOME_COLOURS {
+    if present[biome] {
+      legend = append(legend, legendEntry{ colour, BIOME_NAMES[biome] })
+    }
+  }
+  if trees {
+    legend = append(legend, legendEntry{ TREE_COLOUR, "trees" })
+  }
+  if rocks {
+    legend = append(legend, legendEntry{ ROCK_COLOUR, "rocks" })
+  }
+  seeds := output.seeds
+  seedLines := []string{
+    "height  " + hexSeed(seeds.Height),
+    "tree    " + hexSeed(seeds.Tree),
+    "plant   " + hexSeed(seeds.Plant),
+    "rock    " + hexSeed(seeds.Rock),
+    "temp    " + hexSeed(seeds.Temperature),
+    "soil    " + hexSeed(seeds.Soil),
+  }
+
+  // Size the panel beside the map to fit the widest of its contents.
+  panelWidth := COMPASS_SIZE
+  for _, entry := range legend {
+    width := POSTER_SWATCH + 8 + TextWidth(entry.name, POSTER_TEXT)
+    if width > panelWidth {
+      panelWidth = width
+    }
+  }
+  for _, line := range seedLines {
+    if width := TextWidth(line, 1); width > panelWidth {
+      panelWidth = width
+    }
+  }
+  panelHeight := POSTER_LINE * (len(legend) + 1) + POSTER_MARGIN +
+                 COMPASS_SIZE + 4 + POSTER_LINE + POSTER_MARGIN +
+                 POSTER_LINE + POSTER_SMALL_LINE * len(seedLines)
+
+  mapWidth := w.width * scale
+  mapHeight := w.height * scale
+  mapRect := image.Rect(POSTER_MARGIN, POSTER_MARGIN,
+                        POSTER_MARGIN + mapWidth, POSTER_MARGIN + mapHeight)
+  barHeight := FONT_HEIGHT * POSTER_TEXT
+  width := mapRect.Max.X + POSTER_MARGIN + panelWidth + POSTER_MARGIN
+  height := mapRect.Max.Y + POSTER_MARGIN + barHeight + POSTER_MARGIN
+  if h := POSTER_MARGIN * 2 + panelHeight; h > height {
+    height = h
+  }
+  poster := image.NewRGBA(image.Rect(0, 0, width, height))
+  draw.Draw(poster, poster.Bounds(), &image.Uniform{ POSTER_PAPER },
+            image.Point{}, draw.Src)
+  draw.Draw(poster, mapRect.Inset(-2), &image.Uniform{ POSTER_INK },
+            image.Point{}, draw.Src)
+  draw.Draw(poster, mapRect, Upscale(DrawOverworld(w, nil), scale),
+            image.Point{}, draw.Src)
+
+  if labels {
+    placed := make([]image.Rectangle, 0)
+    textHeight := FONT_HEIGHT * POSTER_TEXT
+    for _, place := range FindPlaces(w, seeds.Height) {
+      px := mapRect.Min.X + place.x * scale + scale / 2
+      py := mapRect.Min.Y + place.y * scale + scale / 2
+      textWidth := TextWidth(place.name, POSTER_TEXT)
+      r := image.Rect(px - textWidth / 2, py - textHeight / 2,
+                      px + textWidth / 2, py + textHeight / 2)
+      if place.peak {
+        r = r.Sub(image.Pt(0, textHeight / 2 + 8))
+      }
+      if !r.In(mapRect) {
+        continue
+      }
+      overlaps := false
+      for _, other := range placed {
+        if r.Inset(-4).Overlaps(other) {
+          overlaps = true
+          break
+        }
+      }
+      if overlaps {
+        continue
+      }
+      placed = append(placed, r)
+      if place.peak {
+        drawLine(poster, px - 5, py + 3, px, py - 4, 2, POSTER_INK)
+        drawLine(poster, px, py - 4, px + 5, py + 3, 2, POSTER_INK)
+      }
+      DrawOutlinedText(poster, r.Min.X, r.Min.Y, place.name, POSTER_TEXT,
+                       POSTER_LABEL, POSTER_INK)
+    }
+  }
+
+  drawScaleBar(poster, mapRect.Min.X,
+               mapRect.Max.Y + POSTER_MARGIN + (barHeight - SCALE_BAR_HEIGHT) / 2,
+               scaleBarLength(w.width / 4), scale)
+
+  x := mapRect.Max.X + POSTER_MARGIN
+  y := POSTER_MARGIN
+  DrawText(poster, x, y, "Legend", POSTER_TEXT, POSTER_INK)
+  y += POSTER_LINE
+  for _, entry := range legend {
+    swatch := image.Rect(x, y, x + POSTER_SWATCH, y + POSTER_SWATCH)
+    draw.Draw(poster, swatch, &image.Uniform{ POSTER_INK }, image.Point{},
+              draw.Src)
+    draw.Draw(poster, swatch.Inset(1), &image.Uniform{ entry.colour },
+              image.Point{}, draw.Src)
+    DrawText(poster, x + POSTER_SWATCH + 8, y, entry.name, POSTER_TEXT,
+             POSTER_INK)
+    y += POSTER_LINE
+  }
+  y += POSTER_MARGIN
+  drawCompass(poster, x + COMPASS_SIZE / 2, y + COMPASS_SIZE / 2, w.windDir)
+  y += COMPASS_SIZE + 4
+  wind := "wind"
+  DrawText(poster, x + (COMPASS_SIZE - TextWidth(wind, POSTER_TEXT)) / 2, y,
+           wind, POSTER_TEXT, POSTER_WIND)
+  y += POSTER_LINE + POSTER_MARGIN
+  DrawText(poster, x, y, "Seeds", POSTER_TEXT, POSTER_INK)
+  y += POSTER_LINE
+  for _, line := range seedLines {
+    DrawText(poster, x, y, line, 1, POSTER_INK)
+    y += POSTER_SMALL_LINE
+  }
+
+  enc := &png.Encoder { CompressionLevel: png.BestSpeed, }
+  if err := writePNG(output.Path("-poster.png"), poster, enc); err != nil {
+    return err
+  }
+  fmt.Println("poster created.")
+  return nil
+}
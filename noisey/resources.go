@@ -0,0 +1,47 @@
+package noisey
+
+import (
+  "embed"
+  "errors"
+  "io/fs"
+  "os"
+)
+
+// The default spritesheets, manifests and tilesets, built into the binary so
+// that it can be run from any directory.
+//go:embed res
+var embeddedResources embed.FS
+
+// The resources which sheets, manifests and tilesets are loaded from, where
+// the files of an optional directory replace the embedded ones, file by
+// file.
+type overlayFS struct {
+  dir fs.FS
+  base fs.FS
+}
+
+func (o overlayFS) Open(name string) (fs.File, error) {
+  if o.dir != nil {
+    file, err := o.dir.Open(name)
+    if err == nil {
+      return file, nil
+    }
+    if !errors.Is(err, fs.ErrNotExist) {
+      return nil, err
+    }
+  }
+  return o.base.Open(name)
+}
+
+// Create the resources, named as they are within res, with the files in 'dir'
+// replacing the embedded ones, or only the embedded ones if 'dir' is empty.
+func CreateResources(dir string) (fs.FS, error) {
+  base, err := fs.Sub(embeddedResources, "res")
+  if err != nil {
+    return nil, newError(ErrMissingResource, "res", err)
+  }
+  if dir == "" {
+    return base, nil
+  }
+  return overlayFS{ os.DirFS(dir), base }, nil
+}
@@ -0,0 +1,66 @@
+package noisey
+
+import (
+  "image/color"
+  "strings"
+)
+
+const (
+  SPRING = iota
+  SUMMER
+  AUTUMN
+  WINTER
+  NUM_SEASONS
+)
+
+var SEASON_NAMES = [NUM_SEASONS]string {
+  "spring",
+  "summer",
+  "autumn",
+  "winter",
+}
+
+// The replacement name, in a manifest's seasons, for a sprite which isn't
+// drawn in that season.
+const NO_SPRITE = ""
+
+// The colour the ground is blended towards, and by how much, in each season.
+// Winter covers everything but the water in a layer of snow.
+var SEASON_TINTS = [NUM_SEASONS]color.RGBA {
+  { 120, 200, 80, 20 },   // SPRING
+  { 0, 0, 0, 0 },         // SUMMER
+  { 200, 120, 40, 50 },   // AUTUMN
+  { 245, 248, 252, 200 }, // WINTER
+}
+
+// Parse a comma separated list of season names, or 'all'.
+func ParseSeasons(names string) ([]int, error) {
+  if names == "all" {
+    return []int{ SPRING, SUMMER, AUTUMN, WINTER }, nil
+  }
+  seasons := make([]int, 0, NUM_SEASONS)
+  var given [NUM_SEASONS]bool
+  for _, name := range strings.Split(names, ",") {
+    found := false
+    for season, seasonName := range SEASON_NAMES {
+      if name == seasonName {
+        // Each season's maps are named after it, so a repeat would overwrite
+        // them.
+        if given[season] {
+          return nil, newError(ErrBadParameter,
+                               "Season '" + name + "' is given twice", nil)
+        }
+        given[season] = true
+        seasons = append(seasons, season)
+        found = true
+        break
+      }
+    }
+    if !found {
+      return nil, newError(ErrBadParameter,
+                           "Invalid season '" + name +
+                           "', choose: spring,summer,autumn,winter or all", nil)
+    }
+  }
+  return seasons, nil
+}
@@ -0,0 +1,34 @@
+package noisey
+
+import (
+  "errors"
+  "reflect"
+  "testing"
+)
+
+func TestParseSeasons(t *testing.T) {
+  tests := []struct {
+    names string
+    want []int
+  } {
+    { "summer", []int{ SUMMER } },
+    { "winter,spring", []int{ WINTER, SPRING } },
+    { "all", []int{ SPRING, SUMMER, AUTUMN, WINTER } },
+    { "summer,summer", nil },
+    { "spring,winter,spring", nil },
+    { "monsoon", nil },
+    { "", nil },
+  }
+  for _, test := range tests {
+    got, err := ParseSeasons(test.names)
+    if test.want == nil {
+      if !errors.Is(err, ErrBadParameter) {
+        t.Errorf("%q: got %v and error %v, want a bad parameter", test.names,
+                 got, err)
+      }
+    } else if err != nil || !reflect.DeepEqual(got, test.want) {
+      t.Errorf("%q: got %v and error %v, want %v", test.names, got, err,
+               test.want)
+    }
+  }
+}
@@ -0,0 +1,219 @@
+package noisey
+
+import (
+  "image"
+  "image/color"
+  "image/png"
+  "math"
+  "math/rand"
+  "os"
+)
+
+// A shape gives the distance of a location from the centre of the land, where
+// 0 is the middle of the land and 1 is where the land should have fallen away
+// into the sea. The height of the location is then lowered by the distance.
+type ShapeFunc func(x, y int) float64
+
+// Calculate the position of a location relative to the centre of the map,
+// in the range [-1, 1] in both axes.
+func normalisedPos(x, y, width, height int) (float64, float64) {
+  cx := float64(width) / 2
+  cy := float64(height) / 2
+  return (float64(x) - cx) / cx, (float64(y) - cy) / cy
+}
+
+// No falloff at all, the noise alone decides where the land is.
+func NoShape(width, height int) ShapeFunc {
+  return func(x, y int) float64 {
+    return 0
+  }
+}
+
+// The distance to the nearest edge of the map.
+func SquareShape(width, height int) ShapeFunc {
+  return func(x, y int) float64 {
+    nx, ny := normalisedPos(x, y, width, height)
+    return math.Max(math.Abs(nx), math.Abs(ny))
+  }
+}
+
+// A circle, which touches the nearest pair of edges.
+func RadialShape(width, height int) ShapeFunc {
+  radius := math.Min(float64(width), float64(height)) / 2
+  return func(x, y int) float64 {
+    dx := float64(x) - float64(width) / 2
+    dy := float64(y) - float64(height) / 2
+    return math.Sqrt(dx * dx + dy * dy) / radius
+  }
+}
+
+// An ellipse, which touches all four edges.
+func EllipticalShape(width, height int) ShapeFunc {
+  return func(x, y int) float64 {
+    nx, ny := normalisedPos(x, y, width, height)
+    return math.Sqrt(nx * nx + ny * ny)
+  }
+}
+
+// A number of smaller islands, with centres randomly placed away from the
+// edges of the map.
+func ArchipelagoShape(width, height, islands int, seed int64,
+                      wrapX, wrapY bool) ShapeFunc {
+  rng := rand.New(rand.NewSource(seed))
+  cxs := make([]float64, islands)
+  cys := make([]float64, islands)
+  for i := 0; i < islands; i++ {
+    cxs[i] = (0.15 + 0.7 * rng.Float64()) * float64(width)
+    cys[i] = (0.15 + 0.7 * rng.Float64()) * float64(height)
+  }
+  // Make each island large enough that, together, they cover a similar area
+  // to a single island.
+  radius := math.Min(float64(width), float64(height)) /
+            (2 * math.Sqrt(float64(islands)))
+  return func(x, y int) float64 {
+    nearest := math.Inf(1)
+    for i := 0; i < islands; i++ {
+      dx := math.Abs(float64(x) - cxs[i])
+      dy := math.Abs(float64(y) - cys[i])
+      // Islands near one edge of a wrapping map also reach across the other.
+      if wrapX {
+        dx = math.Min(dx, float64(width) - dx)
+      }
+      if wrapY {
+        dy = math.Min(dy, float64(height) - dy)
+      }
+      nearest = math.Min(nearest, math.Sqrt(dx * dx + dy * dy))
+    }
+    return nearest / radius
+  }
+}
+
+// Land which continues off the map at the 'open' edge, so only the other
+// three edges fall away into the sea.
+func ContinentShape(width, height int, open uint) ShapeFunc {
+  return func(x, y int) float64 {
+    nx, ny := normalisedPos(x, y, width, height)
+    // Distance from the open edge, in the range [0, 1].
+    var across, along float64
+    switch open {
+    case NORTH:
+      across, along = nx, (ny + 1) / 2
+    case SOUTH:
+      across, along = nx, (1 - ny) / 2
+    case EAST:
+      across, along = ny, (1 - nx) / 2
+    default:
+      across, along = ny, (nx + 1) / 2
+    }
+    return math.Max(math.Abs(across), along)
+  }
+}
+
+// Use a grayscale image to define the outline of the land, where white is
+// land and black is sea. The image is stretched to cover the whole map.
+func MaskShape(width, height int, filename string) (ShapeFunc, error) {
+  file, err := os.Open(filename)
+  if err != nil {
+    return nil, openError(filename, err)
+  }
+  defer file.Close()
+  img, err := png.Decode(file)
+  if err != nil {
+    return nil, newError(ErrBadResource, filename, err)
+  }
+  mask := sampleGray(img, width, height)
+  return func(x, y int) float64 {
+    return 1 - mask[y * width + x]
+  }, nil
+}
+
+func clampInt(v, min, max int) int {
+  if v < min {
+    return min
+  } else if v > max {
+    return max
+  }
+  return v
+}
+
+// Resample an image to width x height values in the range [0, 1], by
+// bilinear interpolation of its grayscale value.
+func sampleGray(img image.Image, width, height int) []float64 {
+  bounds := img.Bounds()
+  gray := func(x, y int) float64 {
+    x = bounds.Min.X + clampInt(x, 0, bounds.Dx() - 1)
+    y = bounds.Min.Y + clampInt(y, 0, bounds.Dy() - 1)
+    g := color.Gray16Model.Convert(img.At(x, y)).(color.Gray16)
+    return float64(g.Y) / 0xffff
+  }
+
+  values := make([]float64, width * height)
+  scaleX := float64(bounds.Dx()) / float64(width)
+  scaleY := float64(bounds.Dy()) / float64(height)
+  for y := 0; y < height; y++ {
+    fy := (float64(y) + 0.5) * scaleY - 0.5
+    y0 := int(math.Floor(fy))
+    ty := fy - float64(y0)
+    for x := 0; x < width; x++ {
+      fx := (float64(x) + 0.5) * scaleX - 0.5
+      x0 := int(math.Floor(fx))
+      tx := fx - float64(x0)
+      top := gray(x0, y0) * (1 - tx) + gray(x0 + 1, y0) * tx
+      bottom := gray(x0, y0 + 1) * (1 - tx) + gray(x0 + 1, y0 + 1) * tx
+      values[y * width + x] = top * (1 - ty) + bottom * ty
+    }
+  }
+  return values
+}
+
+// Remove the falloff along the axes which wrap, by always measuring the shape
+// from the middle of that axis.
+func WrapShape(shape ShapeFunc, width, height int,
+               wrapX, wrapY bool) ShapeFunc {
+  return func(x, y int) float64 {
+    if wrapX {
+      x = width / 2
+    }
+    if wrapY {
+      y = height / 2
+    }
+    return shape(x, y)
+  }
+}
+
+// Create the shape named in the config. Apart from an archipelago, whose
+// islands wrap around with the map, and a mask, which is used as drawn, the
+// shape only falls away along the axes which don't wrap.
+func CreateShape(config *Config, seed int64) (ShapeFunc, error) {
+  width := config.Width
+  height := config.Height
+  wrap := func(shape ShapeFunc) ShapeFunc {
+    return WrapShape(shape, width, height, config.WrapX, config.WrapY)
+  }
+  if config.Mask != "" && config.Shape != "mask" {
+    return nil, newError(ErrBadParameter,
+                         "a mask is only used by the mask shape, so also " +
+                         "give -shape mask", nil)
+  }
+  switch config.Shape {
+  case "none":
+    return NoShape(width, height), nil
+  case "square":
+    return wrap(SquareShape(width, height)), nil
+  case "radial":
+    return wrap(RadialShape(width, height)), nil
+  case "elliptical":
+    return wrap(EllipticalShape(width, height)), nil
+  case "archipelago":
+    return ArchipelagoShape(width, height, config.Islands, seed,
+                            config.WrapX, config.WrapY), nil
+  case "continent":
+    return wrap(ContinentShape(width, height, config.OpenEdge)), nil
+  case "mask":
+    return MaskShape(width, height, config.Mask)
+  }
+  return nil, newError(ErrBadParameter,
+                       "Invalid shape '" + config.Shape + "', choose: " +
+                       "none,square,radial,elliptical,archipelago," +
+                       "continent or mask", nil)
+}
@@ -0,0 +1,52 @@
+package noisey
+
+import (
+  "math"
+)
+
+func (w World) Soil(x, y int) float64 {
+  return w.locations[y * w.width + x].soil
+}
+
+func (w World) SetSoil(x, y int, s float64) {
+  w.locations[y * w.width + x].soil = s
+}
+
+// The steepest height difference between a location and its direct
+// neighbours.
+func (w World) Slope(x, y int) float64 {
+  h := w.Height(x, y)
+  slope := 0.0
+  for dir := NORTH; dir < MAX_DIR; dir += 2 {
+    loc := w.WrappedLocation(x + DIR_DELTA_X[dir], y + DIR_DELTA_Y[dir])
+    if loc == nil {
+      continue
+    }
+    slope = math.Max(slope, math.Abs(loc.height - h))
+  }
+  return slope
+}
+
+// Soil depth starts from a noise layer and is then thinned out on steep
+// slopes, where it gets washed away, and as the land rises into the hills.
+// Depths below NO_SOIL are bare rock, below SHALLOW_SOIL are thin and above
+// THICK_SOIL are deep.
+func (w World) CalcSoil(xBegin, xEnd int, noise *Fractal, c chan int) {
+  width := w.width
+  height := w.height
+
+  for y := 0; y < height; y++ {
+    yFloat := float64(y) / float64(height)
+    for x := xBegin; x < xEnd; x++ {
+      xFloat := float64(x) / float64(width)
+      s := noise.Eval(xFloat, yFloat)
+
+      s -= w.soilSlope * w.Slope(x, y)
+      if h := w.Height(x, y); h > LOWLANDS {
+        s -= w.soilHeight * (h - LOWLANDS)
+      }
+      w.SetSoil(x, y, s)
+    }
+  }
+  c <- 1
+}
@@ -0,0 +1,205 @@
+package noisey
+
+import (
+  "image"
+  "image/color"
+  "image/draw"
+  "image/png"
+  "io/fs"
+  "math/rand"
+)
+
+type SpriteSheet struct {
+  tileWidth, tileHeight, tileColumns, tileRows int
+  spritesheet image.Image
+  sprites []image.Rectangle
+  // Sheets loaded from a manifest can also be drawn from by name, where each
+  // name has one or more variants.
+  manifest *SpriteManifest
+  names map[string][]int
+  // The sprite for each frame of an animated variant, by the variant.
+  frames map[int][]int
+}
+
+// Create a sheet of cols x rows tiles, each tileWidth x tileHeight pixels,
+// from an image in the resources.
+func CreateSheet(resources fs.FS, filename string, cols, rows, tileWidth,
+                 tileHeight int) (*SpriteSheet, error) {
+  tilesheetFile, err := resources.Open(filename)
+  if err != nil {
+    return nil, openError(filename, err)
+  }
+  defer tilesheetFile.Close()
+
+  spritesheet, err := png.Decode(tilesheetFile)
+  if err != nil {
+    return nil, newError(ErrBadResource, filename, err)
+  }
+
+  sheet := new(SpriteSheet)
+  sheet.tileWidth = tileWidth
+  sheet.tileHeight = tileHeight
+  sheet.tileColumns = cols
+  sheet.tileRows = rows
+  sheet.spritesheet = spritesheet
+  sheet.sprites = make([]image.Rectangle, cols * rows)
+  for y := 0; y < rows; y++ {
+    for x := 0; x < cols; x++ {
+      idx := y * cols + x
+      sheet.sprites[idx] = image.Rect(x * tileWidth, y * tileHeight,
+                                      x * tileWidth + tileWidth,
+                                      y * tileHeight + tileHeight)
+    }
+  }
+  return sheet, nil
+}
+
+// Create a sheet from a manifest in the resources, with a sprite for every
+// cell of every entry.
+func LoadSheet(resources fs.FS, manifestFile string) (*SpriteSheet, error) {
+  m, err := LoadManifest(resources, manifestFile)
+  if err != nil {
+    return nil, err
+  }
+  sheet, err := CreateSheet(resources, m.Image, 0, 0, m.TileWidth,
+                            m.TileHeight)
+  if err != nil {
+    return nil, err
+  }
+  sheet.manifest = m
+  sheet.names = make(map[string][]int, len(m.Sprites))
+  sheet.frames = make(map[int][]int)
+  for _, entry := range m.Sprites {
+    size := entry.TileSize()
+    offsets := entry.Frames
+    if len(offsets) == 0 {
+      offsets = [][2]int{ { 0, 0 } }
+    }
+    for _, cell := range entry.Cells {
+      variant := len(sheet.sprites)
+      sheet.names[entry.Name] = append(sheet.names[entry.Name], variant)
+      for _, offset := range offsets {
+        x := (cell[0] + offset[0]) * m.TileWidth
+        y := (cell[1] + offset[1]) * m.TileHeight
+        if len(entry.Frames) != 0 {
+          sheet.frames[variant] = append(sheet.frames[variant],
+                                         len(sheet.sprites))
+        }
+        sheet.sprites = append(sheet.sprites,
+                               image.Rect(x, y, x + size.X * m.TileWidth,
+                                          y + size.Y * m.TileHeight))
+      }
+    }
+  }
+  return sheet, nil
+}
+
+// The sprite to draw for a variant in a frame of the animation, which is the
+// variant itself unless it is animated.
+func (sheet *SpriteSheet) Frame(idx, frame int) int {
+  if frames, ok := sheet.frames[idx]; ok {
+    return frames[frame % len(frames)]
+  }
+  return idx
+}
+
+// Pick one of the variants of the named sprite, which has to be in the
+// sheet's manifest.
+func (sheet *SpriteSheet) Variant(name string, rng *rand.Rand) int {
+  variants := sheet.names[name]
+  return variants[rng.Intn(len(variants))]
+}
+
+// The set of colours used in a sprite.
+func (sheet *SpriteSheet) Palette(idx int) map[color.NRGBA]bool {
+  palette := make(map[color.NRGBA]bool)
+  srcR := sheet.sprites[idx]
+  for y := srcR.Min.Y; y < srcR.Max.Y; y++ {
+    for x := srcR.Min.X; x < srcR.Max.X; x++ {
+      c := color.NRGBAModel.Convert(sheet.spritesheet.At(x, y)).(color.NRGBA)
+      palette[c] = true
+    }
+  }
+  return palette
+}
+
+// Create a copy of the sheet with every colour, except those in 'keep',
+// blended towards the tint colour. The alpha of the tint is the strength of
+// the blend.
+func (sheet *SpriteSheet) Tint(tint color.RGBA,
+                               keep map[color.NRGBA]bool) *SpriteSheet {
+  tinted := *sheet
+  if tint.A == 0 {
+    return &tinted
+  }
+  bounds := sheet.spritesheet.Bounds()
+  img := image.NewNRGBA(bounds)
+  amount := uint32(tint.A)
+  blend := func(c, t uint8) uint8 {
+    return uint8((uint32(c) * (255 - amount) + uint32(t) * amount) / 255)
+  }
+  for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+    for x := bounds.Min.X; x < bounds.Max.X; x++ {
+      c := color.NRGBAModel.Convert(sheet.spritesheet.At(x, y)).(color.NRGBA)
+      if c.A != 0 && !keep[c] {
+        c = color.NRGBA{ blend(c.R, tint.R), blend(c.G, tint.G),
+                         blend(c.B, tint.B), c.A }
+      }
+      img.SetNRGBA(x, y, c)
+    }
+  }
+  tinted.spritesheet = img
+  return &tinted
+}
+
+func (sheet *SpriteSheet) DrawFeature(x, y, idx int, img draw.Image) {
+  srcR := sheet.sprites[idx]
+  width := sheet.tileWidth
+  height := sheet.tileHeight
+  destR := image.Rect(x * width, y * height,
+                      x * width + width,
+                      y * height + height)
+  draw.Draw(img, destR, sheet.spritesheet, srcR.Min, draw.Over)
+}
+
+// Draw a sprite, which may cover several tiles, so that the tile at 'anchor'
+// within the sprite lands on the map tile x, y.
+func (sheet *SpriteSheet) DrawSprite(x, y, idx int, anchor image.Point,
+                                     img draw.Image) {
+  srcR := sheet.sprites[idx]
+  px := (x - anchor.X) * sheet.tileWidth
+  py := (y - anchor.Y) * sheet.tileHeight
+  destR := image.Rect(px, py, px + srcR.Dx(), py + srcR.Dy())
+  draw.Draw(img, destR, sheet.spritesheet, srcR.Min, draw.Over)
+}
+
+// Draw a sprite with its top left corner at the pixel px, py.
+func (sheet *SpriteSheet) DrawAt(px, py, idx int, img draw.Image) {
+  srcR := sheet.sprites[idx]
+  destR := image.Rect(px, py, px + srcR.Dx(), py + srcR.Dy())
+  draw.Draw(img, destR, sheet.spritesheet, srcR.Min, draw.Over)
+}
+
+// Draw a tile sized sprite only where the mask, which is the size of a tile,
+// is set.
+func (sheet *SpriteSheet) DrawMasked(x, y, idx int, mask *image.Alpha,
+                                     img draw.Image) {
+  srcR := sheet.sprites[idx]
+  width := sheet.tileWidth
+  height := sheet.tileHeight
+  destR := image.Rect(x * width, y * height,
+                      x * width + width,
+                      y * height + height)
+  draw.DrawMask(img, destR, sheet.spritesheet, srcR.Min, mask,
+                image.Point{}, draw.Over)
+}
+
+func (sheet *SpriteSheet) DrawFloorTile(x, y, idx int, img draw.Image) {
+  srcR := sheet.sprites[idx]
+  width := sheet.tileWidth
+  height := sheet.tileHeight
+  destR := image.Rect(x * width, y * height,
+                      x * width + width,
+                      y * height + height)
+  draw.Draw(img, destR, sheet.spritesheet, srcR.Min, draw.Src)
+}
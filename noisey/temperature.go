@@ -0,0 +1,65 @@
+package noisey
+
+import (
+  "math"
+)
+
+// Sea level temperatures, in degrees celsius, at the equator and the poles.
+const EQUATOR_TEMP = 28.0
+const POLE_TEMP = -20.0
+
+const SNOW_LINE = -6.0
+const FREEZING = 0.0
+const WARM = 12.0
+const HOT = 22.0
+
+// The sea level temperature for a given latitude, in degrees.
+func seaLevelTemperature(latitude float64) float64 {
+  lat := math.Abs(latitude) * math.Pi / 180
+  return POLE_TEMP + (EQUATOR_TEMP - POLE_TEMP) * math.Cos(lat)
+}
+
+// The latitude of the given row, interpolated between the northern and
+// southern edges of the map.
+func (w World) Latitude(y int) float64 {
+  if w.height < 2 {
+    return w.latNorth
+  }
+  t := float64(y) / float64(w.height - 1)
+  return w.latNorth + t * (w.latSouth - w.latNorth)
+}
+
+func (w World) Temperature(x, y int) float64 {
+  return w.locations[y * w.width + x].temperature
+}
+
+func (w World) SetTemperature(x, y int, t float64) {
+  w.locations[y * w.width + x].temperature = t
+}
+
+// Temperature falls with latitude and with height above the sea, by lapseRate
+// degrees per unit of height. An optional noise layer adds local variation of
+// up to tempNoise degrees.
+func (w World) CalcTemperature(xBegin, xEnd int, noise *Fractal, c chan int) {
+  width := w.width
+  height := w.height
+  amplitude := noise.Amplitude()
+
+  for y := 0; y < height; y++ {
+    base := seaLevelTemperature(w.Latitude(y))
+    yFloat := float64(y) / float64(height)
+    for x := xBegin; x < xEnd; x++ {
+      t := base
+      h := w.Height(x, y)
+      if h > WATER_LEVEL {
+        t -= w.lapseRate * (h - WATER_LEVEL)
+      }
+      if w.tempNoise != 0 {
+        xFloat := float64(x) / float64(width)
+        t += w.tempNoise * noise.Eval(xFloat, yFloat) / amplitude
+      }
+      w.SetTemperature(x, y, t)
+    }
+  }
+  c <- 1
+}
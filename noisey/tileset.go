@@ -0,0 +1,66 @@
+package noisey
+
+import (
+  "encoding/json"
+  "io/fs"
+)
+
+// The sprite manifests used to draw the detailed map, which all share one
+// tile size. The biomes each sprite is chosen in are tagged in the
+// manifests, so tilesets of different art and resolutions can be swapped
+// without any code changes.
+type Tileset struct {
+  TileWidth int `json:"tileWidth"`
+  TileHeight int `json:"tileHeight"`
+  Floor string `json:"floor"`
+  Shadows string `json:"shadows"`
+  Trees string `json:"trees"`
+  Plants string `json:"plants"`
+  Rocks string `json:"rocks"`
+  Paths string `json:"paths"`
+  LargeFeatures string `json:"largeFeatures"`
+  // An optional manifest with a "water" sprite, which can be animated, that
+  // is drawn in place of the floor sheet's water.
+  Water string `json:"water,omitempty"`
+  // An optional manifest of the isometric ground tiles and cliff faces, which
+  // don't share the tileset's tile size.
+  Iso string `json:"iso,omitempty"`
+  // Where the tileset and all of its sheets are loaded from.
+  resources fs.FS
+}
+
+// Load a tileset description from the resources, which its sheets are then
+// loaded from too.
+func LoadTileset(resources fs.FS, filename string) (*Tileset, error) {
+  file, err := resources.Open(filename)
+  if err != nil {
+    return nil, openError(filename, err)
+  }
+  defer file.Close()
+
+  tileset := new(Tileset)
+  if err := json.NewDecoder(file).Decode(tileset); err != nil {
+    return nil, newError(ErrBadResource, filename, err)
+  }
+  if tileset.TileWidth <= 0 || tileset.TileHeight <= 0 {
+    return nil, newError(ErrBadResource,
+                         filename + ": tile size must be positive", nil)
+  }
+  tileset.resources = resources
+  return tileset, nil
+}
+
+// Load one of the tileset's sprite manifests, which has to be drawn with the
+// same tile size.
+func (tileset *Tileset) LoadSheet(manifestFile string) (*SpriteSheet, error) {
+  sheet, err := LoadSheet(tileset.resources, manifestFile)
+  if err != nil {
+    return nil, err
+  }
+  if sheet.tileWidth != tileset.TileWidth ||
+     sheet.tileHeight != tileset.TileHeight {
+    return nil, newError(ErrBadResource, manifestFile +
+                         ": tile size doesn't match the tileset", nil)
+  }
+  return sheet, nil
+}
@@ -0,0 +1,134 @@
+package noisey
+
+import (
+  "image"
+  "image/color"
+  "image/color/palette"
+  "image/draw"
+  "image/gif"
+  "math"
+  "os"
+)
+
+// How long each kind of frame is shown for, in 100ths of a second.
+const (
+  STAGE_DELAY = 60
+  CLOUD_DELAY = 8
+  FINAL_DELAY = 300
+)
+
+var TERRACE_COLOURS = [5]color.RGBA{{ 51, 166, 204, 255 },  // under water
+                                    { 255, 230, 128, 255 }, // beach
+                                    { 119, 179, 0, 255 },   // lowlands
+                                    { 128, 153, 51, 255 },  // midlands
+                                    { 166, 166, 166, 255 } } // highlands
+
+var RIVER_COLOUR = color.RGBA{ 0, 60, 200, 255 }
+var RIVER_BANK_COLOUR = color.RGBA{ 140, 90, 40, 255 }
+var PATH_COLOUR = color.RGBA{ 220, 40, 40, 255 }
+
+// Timelapse collects snapshots of the overworld as the world is generated,
+// which are saved as the frames of an animated gif. A nil Timelapse ignores
+// every snapshot, so it can always be passed around.
+type Timelapse struct {
+  scale int
+  cloudSteps int
+  frames []*image.Paletted
+  delays []int
+}
+
+// Create a timelapse with 'scale' pixels per tile which, if cloudSteps is
+// more than zero, also snapshots the moisture every cloudSteps steps of the
+// moisture simulation.
+func CreateTimelapse(scale, cloudSteps int) *Timelapse {
+  t := new(Timelapse)
+  t.scale = scale
+  t.cloudSteps = cloudSteps
+  return t
+}
+
+func heightColour(w *World, x, y int) color.RGBA {
+  h := (w.Location(x, y).height - HEIGHT_MIN) / (HEIGHT_MAX - HEIGHT_MIN)
+  v := uint8(math.Round(math.Max(0, math.Min(1, h)) * 255))
+  return color.RGBA{ v, v, v, 255 }
+}
+
+func terraceColour(w *World, x, y int) color.RGBA {
+  return TERRACE_COLOURS[w.Location(x, y).terrace]
+}
+
+// Moisture, on a log scale, from white when dry to deep blue when well past
+// sodden.
+func moistureColour(w *World, x, y int) color.RGBA {
+  m := math.Log1p(math.Max(w.Location(x, y).moisture, 0)) /
+       math.Log1p(2 * SODDEN)
+  m = math.Min(1, m)
+  return color.RGBA{ uint8(255 * (1 - m)), uint8(255 * (1 - 0.7 * m)), 255, 255 }
+}
+
+// The overworld, with the rivers, their banks and paths picked out.
+func stageColour(w *World, x, y int) color.RGBA {
+  loc := w.Location(x, y)
+  if loc.isRiver {
+    return RIVER_COLOUR
+  } else if loc.isRiverBank {
+    return RIVER_BANK_COLOUR
+  } else if loc.hasFeature(PATH_FEATURE) {
+    return PATH_COLOUR
+  }
+  return overworldColour(w, x, y)
+}
+
+// Add a frame of the world, coloured by 'colour', shown for 'delay'.
+func (t *Timelapse) snapshot(w *World, delay int,
+                             colour func(w *World, x, y int) color.RGBA) {
+  img := image.NewRGBA(image.Rect(0, 0, w.width, w.height))
+  for y := 0; y < w.height; y++ {
+    for x := 0; x < w.width; x++ {
+      img.SetRGBA(x, y, colour(w, x, y))
+    }
+  }
+  scaled := Upscale(img, t.scale)
+  frame := image.NewPaletted(scaled.Bounds(), palette.Plan9)
+  draw.Draw(frame, frame.Bounds(), scaled, image.Point{}, draw.Src)
+  t.frames = append(t.frames, frame)
+  t.delays = append(t.delays, delay)
+}
+
+// Add a frame for a finished stage of the generation.
+func (t *Timelapse) Snapshot(w *World,
+                             colour func(w *World, x, y int) color.RGBA) {
+  if t == nil {
+    return
+  }
+  t.snapshot(w, STAGE_DELAY, colour)
+}
+
+// Add a frame of the moisture, if this is one of the steps to snapshot.
+func (t *Timelapse) CloudStep(w *World, step int) {
+  if t == nil || t.cloudSteps <= 0 || step % t.cloudSteps != 0 {
+    return
+  }
+  t.snapshot(w, CLOUD_DELAY, moistureColour)
+}
+
+// Write the frames to an animated gif, holding on the last one.
+func (t *Timelapse) Save(filename string) error {
+  if t == nil || len(t.frames) == 0 {
+    return nil
+  }
+  t.delays[len(t.delays) - 1] = FINAL_DELAY
+  anim := &gif.GIF{ Image: t.frames, Delay: t.delays }
+  imgFile, err := os.Create(filename)
+  if err != nil {
+    return newError(ErrEncode, filename, err)
+  }
+  if err := gif.EncodeAll(imgFile, anim); err != nil {
+    imgFile.Close()
+    return newError(ErrEncode, filename, err)
+  }
+  if err := imgFile.Close(); err != nil {
+    return newError(ErrEncode, filename, err)
+  }
+  return nil
+}
@@ -1,17 +1,13 @@
-package main
+package noisey
 
 import (
   "container/heap"
-  "flag"
   "fmt"
   "math"
-  "math/rand"
   "sort"
   "time"
 )
 
-import "github.com/ojrac/opensimplex-go"
-
 const REGION_SIZE = 8
 const REGION_AREA = REGION_SIZE * REGION_SIZE
 
@@ -28,6 +24,12 @@ var TREE_DENSITY = [BIOMES]int {
   4,  // FENLAND
   8,  // WOODLAND
   10, // FOREST
+  1,  // SNOW
+  1,  // TUNDRA
+  0,  // DESERT
+  2,  // SAVANNA
+  1,  // MARSH
+  12, // RAINFOREST
 }
 
 var PLANT_DENSITY = [BIOMES]int {
@@ -43,6 +45,12 @@ var PLANT_DENSITY = [BIOMES]int {
   8, // FENLAND
   5,  // WOODLAND
   4,  // FOREST
+  0,  // SNOW
+  3,  // TUNDRA
+  1,  // DESERT
+  5,  // SAVANNA
+  9,  // MARSH
+  6,  // RAINFOREST
 }
 
 var ROCK_DENSITY = [BIOMES]int {
@@ -58,6 +66,12 @@ var ROCK_DENSITY = [BIOMES]int {
   1,  // FENLAND
   2,  // WOODLAND
   1,  // FOREST
+  3,  // SNOW
+  4,  // TUNDRA
+  3,  // DESERT
+  2,  // SAVANNA
+  0,  // MARSH
+  1,  // RAINFOREST
 }
 
 const (
@@ -96,30 +110,45 @@ const NO_SOIL = -1.5
 const DRY = 0
 const MOIST = 0.4
 const WET = 0.9
+const ARID = 5
+const SODDEN = 15
 const THICK_SOIL = -0.2
 const SHALLOW_SOIL = -0.7
+const SOIL_BIAS = 0.25
 
 type World struct {
   width, height int
+  wrapX, wrapY bool
+  windDir uint
   locations []Location
   shoreline []*Location
   regions []Location
-  clouds []*Cloud
-  hFreq, tFreq, pFreq, rFreq, water float64
+  latNorth, latSouth, lapseRate, tempNoise float64
+  soilSlope, soilHeight float64
+  placement int
+  // The largest magnitude of the tree, plant and rock noise.
+  treeRange, plantRange, rockRange float64
 }
 
-func CreateWorld(width, height int, windDir uint, hFreq, tFreq, pFreq, rFreq,
-                 water float64) *World {
+func CreateWorld(config *Config) *World {
+  width := config.Width
+  height := config.Height
   w := new(World)
   w.width = width;
   w.height = height;
+  w.wrapX = config.WrapX
+  w.wrapY = config.WrapY
+  w.windDir = config.WindDir
   w.locations = make([]Location, width * height)
-  w.regions = make([]Location, width * height / REGION_AREA)
+  w.regions = make([]Location, regionsAcross(width) * regionsAcross(height))
   w.shoreline = make([]*Location, 0, 50)
-  w.hFreq = hFreq
-  w.tFreq = tFreq
-  w.pFreq = pFreq
-  w.rFreq = rFreq
+  w.latNorth = config.LatNorth
+  w.latSouth = config.LatSouth
+  w.lapseRate = config.LapseRate
+  w.tempNoise = config.TempNoise
+  w.soilSlope = config.SoilSlope
+  w.soilHeight = config.SoilHeight
+  w.placement = config.Placement
 
   for y := 0; y < height; y++ {
     for x := 0; x < width; x++ {
@@ -128,49 +157,6 @@ func CreateWorld(width, height int, windDir uint, hFreq, tFreq, pFreq, rFreq,
       loc.y = y
     }
   }
-
-  numClouds := 0
-  sx := 0
-  sy := 0
-  ex := 0
-  ey := 0
-  if windDir == NORTH {
-    numClouds = width
-    sx = 0
-    sy = height - 1
-    ex = width - 1
-    ey = height - 1
-  } else if windDir == SOUTH {
-    numClouds = width
-    sx = 0
-    sy = 0
-    ex = width - 1
-    ey = 0
-  } else if windDir == EAST {
-    numClouds = height
-    sx = 0
-    sy = 0
-    ex = 0
-    ey = height - 1
-  } else if windDir == WEST {
-    numClouds = height
-    sx = width - 1
-    sy = 0
-    ex = width - 1
-    ey = height - 1
-  }
-
-  w.clouds = make([]*Cloud, numClouds)
-  fmt.Println("sx,sy", sx,sy)
-  fmt.Println("ex,ey", ex,ey)
-  i := 0
-  for x := sx; x <= ex; x++ {
-    for y := sy; y <= ey; y++ {
-      loc := w.Location(x, y)
-      w.clouds[i] = CreateCloud(water, windDir, loc, w);
-      i++
-    }
-  }
   return w
 }
 
@@ -186,10 +172,17 @@ func (w World) Location(x, y int) *Location {
   return &w.locations[y * w.width + x]
 }
 
+// The number of regions needed to cover 'size' locations, where the last one
+// may only be partially covered.
+func regionsAcross(size int) int {
+  return (size + REGION_SIZE - 1) / REGION_SIZE
+}
+
+// The region which contains the location x, y.
 func (w World) Region(x, y int) *Location {
-  rx := x % REGION_SIZE
-  ry := y % REGION_SIZE
-  return &w.regions[ry * w.width / REGION_SIZE + rx]
+  rx := x / REGION_SIZE
+  ry := y / REGION_SIZE
+  return &w.regions[ry * regionsAcross(w.width) + rx]
 }
 
 func (w World) Moisture(x, y int) float64 {
@@ -248,61 +241,66 @@ func (w World) SetBiome(x, y int, b uint8) {
   w.Location(x, y).biome = b
 }
 
-func (w World) getDirectedLocation(loc *Location, dir uint) *Location {
-  x := loc.x + DIR_DELTA_X[dir]
-  y := loc.y + DIR_DELTA_Y[dir]
-  if x >= 0 && x < w.width && y >= 0 && y < w.height {
-    res := w.Location(x, y)
-    return res
+// Wrap x, y around the edges of the map, on the axes which wrap. Returns
+// false if the position is still off the map.
+func (w World) wrap(x, y int) (int, int, bool) {
+  if w.wrapX {
+    x = (x % w.width + w.width) % w.width
+  }
+  if w.wrapY {
+    y = (y % w.height + w.height) % w.height
+  }
+  return x, y, x >= 0 && x < w.width && y >= 0 && y < w.height
+}
+
+// The location at x, y, wrapping around the edges of the map on the axes which
+// wrap, or nil if it is off the map.
+func (w World) WrappedLocation(x, y int) *Location {
+  if x, y, ok := w.wrap(x, y); ok {
+    return w.Location(x, y)
   }
   return nil
 }
 
-func (w World) addCloud(parent *Cloud, dir uint) {
-  loc := w.getDirectedLocation(parent.loc, dir)
-  w.clouds = append(w.clouds, CreateCloud(parent.moisture / 3, dir, loc, &w))
+func (w World) getDirectedLocation(loc *Location, dir uint) *Location {
+  return w.WrappedLocation(loc.x + DIR_DELTA_X[dir], loc.y + DIR_DELTA_Y[dir])
 }
 
 func (w World) isRiverValid(centre *Location) bool {
   if centre.biome == OCEAN {
     return false
   }
-  if centre.x <= 0 || centre.y <= 0 ||
-     centre.x + 1 >= w.width || centre.y + 1 >= w.height {
-    return false
-  }
 
   for x := -1; x < 2; x++ {
     for y := -1; y < 2; y++ {
-      loc := w.Location(centre.x + x, centre.y + y)
+      loc := w.WrappedLocation(centre.x + x, centre.y + y)
+      if loc == nil {
+        return false
+      }
+      east := w.WrappedLocation(loc.x + 1, loc.y)
+      west := w.WrappedLocation(loc.x - 1, loc.y)
+      NE := w.WrappedLocation(loc.x + 1, loc.y - 1)
+      NW := w.WrappedLocation(loc.x - 1, loc.y - 1)
 
-      if loc.x + 1 < w.width  && loc.y - 1 >= 0 {
-        east := w.Location(loc.x + 1, loc.y)
-        NE := w.Location(loc.x + 1, loc.y - 1)
+      if east != nil && NE != nil {
         if loc.terrace < east.terrace && !NE.isRiver {
           return false
         }
       }
 
-      if loc.x + 1 < w.width && loc.y - 1 >= 0 && loc.x - 1 >= 0 {
-        east := w.Location(loc.x + 1, loc.y)
-        NW := w.Location(loc.x - 1, loc.y - 1)
+      if east != nil && NW != nil {
         if loc.terrace > east.terrace && !NW.isRiver {
           return false
         }
       }
 
-      if loc.x - 1 >= 0 && loc.y - 1 >= 0 {
-        west := w.Location(loc.x - 1, loc.y)
-        NW := w.Location(loc.x - 1, loc.y - 1)
+      if west != nil && NW != nil {
         if loc.terrace < west.terrace && !NW.isRiver {
           return false
         }
       }
 
-      if loc.x - 1 >= 0 && loc.y - 1 >= 0 && loc.x + 1 < w.width {
-        west := w.Location(loc.x - 1, loc.y)
-        NE := w.Location(loc.x + 1, loc.y - 1)
+      if west != nil && NE != nil {
         if loc.terrace > west.terrace && !NE.isRiver {
           return false
         }
@@ -316,22 +314,31 @@ func (w World) AddWater(loc *Location) {
   loc.isRiver = true
   // Square up the water so that a body of water is a minimum of 3x3 tiles.
   // This allows for a puddle of water to be surrounded in suitable tiles.
-  if loc.x > 1 && loc.y > 1 && loc.x + 1 < w.width && loc.y + 1 < w.height {
-    for x := -1; x < 2; x++ {
-      for y := -1; y < 2; y++ {
-        adjLoc := w.Location(loc.x + x, loc.y + y)
-        if adjLoc.biome == BEACH {
-          adjLoc.biome = OCEAN
-          continue
-        } else if adjLoc.biome == OCEAN {
-          continue
-        }
-        adjLoc.isRiver = true
+  adjacent := make([]*Location, 0, 9)
+  for x := -1; x < 2; x++ {
+    for y := -1; y < 2; y++ {
+      adjLoc := w.WrappedLocation(loc.x + x, loc.y + y)
+      if adjLoc == nil {
+        return
       }
+      adjacent = append(adjacent, adjLoc)
+    }
+  }
+  for _, adjLoc := range adjacent {
+    if adjLoc.biome == BEACH {
+      adjLoc.biome = OCEAN
+      continue
+    } else if adjLoc.biome == OCEAN {
+      continue
     }
+    adjLoc.isRiver = true
   }
 }
 
+// Let the moisture flow downhill, from the highest location to the lowest,
+// accumulating in each location it passes through. Locations whose moisture
+// reaches the saturation level become rivers. The accumulated moisture is
+// also kept as the flow, for the exported layers.
 func (w World) AddRivers(saturate float64) {
   queue := make([]Location, len(w.locations))
   copy(queue, w.locations)
@@ -368,6 +375,7 @@ func (w World) AddRivers(saturate float64) {
   for y := 0; y < w.height; y++ {
     for x := 0; x < w.width; x++ {
       loc := w.Location(x, y)
+      loc.flow = loc.moisture
       if loc.isRiver || loc.biome == OCEAN || loc.moisture < saturate {
         continue
       }
@@ -397,6 +405,12 @@ func (w World) AddGroundFeature(xBegin, xEnd int, c chan int) {
   FENLAND
   WOODLAND
   FOREST
+  SNOW
+  TUNDRA
+  DESERT
+  SAVANNA
+  MARSH
+  RAINFOREST
   */
   for y := 0; y < w.height; y++ {
     for x := xBegin; x < xEnd; x++ {
@@ -413,14 +427,10 @@ func (w World) AddGroundFeature(xBegin, xEnd int, c chan int) {
             continue
           }
 
-          if x + dx >= w.width || x + dx < 0 {
+          otherLoc := w.WrappedLocation(x + dx, y + dy)
+          if otherLoc == nil {
             continue
           }
-          if y + dy >= w.height || y + dy < 0 {
-            continue
-          }
-
-          otherLoc := w.Location(x + dx, y + dy)
           if otherLoc.isRiver || otherLoc.biome == OCEAN ||
             otherLoc.terrace != loc.terrace || otherLoc.biome == loc.biome {
             continue
@@ -458,21 +468,17 @@ func (w World) AddRiverBanks(xBegin, xEnd int, c chan int) {
       S := false
       W := false
 
-      if y > 0 {
-        biome := w.Location(x, y - 1).biome
-        N = !w.Location(x, y - 1).isRiver && biome != OCEAN 
+      if north := w.WrappedLocation(x, y - 1); north != nil {
+        N = !north.isRiver && north.biome != OCEAN
       }
-      if y < w.height - 1 {
-        biome := w.Location(x, y + 1).biome
-        S = !w.Location(x, y + 1).isRiver && biome != OCEAN
+      if south := w.WrappedLocation(x, y + 1); south != nil {
+        S = !south.isRiver && south.biome != OCEAN
       }
-      if x > 0 {
-        biome := w.Location(x - 1, y).biome
-        W = !w.Location(x - 1, y).isRiver && biome != OCEAN
+      if west := w.WrappedLocation(x - 1, y); west != nil {
+        W = !west.isRiver && west.biome != OCEAN
       }
-      if x < w.width - 1 {
-        biome := w.Location(x + 1, y).biome
-        E = !w.Location(x + 1, y).isRiver && biome != OCEAN
+      if east := w.WrappedLocation(x + 1, y); east != nil {
+        E = !east.isRiver && east.biome != OCEAN
       }
 
       // Location == land:
@@ -508,6 +514,19 @@ func (w World) AddRiverBanks(xBegin, xEnd int, c chan int) {
   c <-1 
 }
 
+// A density multiplier, between 0 and 2, from how far the soil depth is from
+// the middle of the shallow to thick range.
+func soilScale(soil, weight float64) float64 {
+  scale := 1 + weight * (soil - (SHALLOW_SOIL + THICK_SOIL) / 2)
+  return math.Max(0, math.Min(2, scale))
+}
+
+// Scale a region's feature count by its average soil depth, up to double the
+// count.
+func soilDensity(count int, soil, weight float64) int {
+  return int(math.Round(float64(count) * soilScale(soil, weight)))
+}
+
 func (w World) AnalyseRegions(xBegin, xEnd int, c chan int) {
   // Divide the world into regions and calculate attributes of each region.
   // If a loc is a 16x16 tile, a region could be 64x64 tiles.
@@ -524,28 +543,47 @@ func (w World) AnalyseRegions(xBegin, xEnd int, c chan int) {
   // the required number of locations for each tree, rock and plant.
   for y := 0; y < w.height; y += REGION_SIZE {
     for x := xBegin; x < xEnd; x += REGION_SIZE {
+      // Regions along the right and bottom edges may be cut short.
+      xLimit := x + REGION_SIZE
+      if xLimit > xEnd {
+        xLimit = xEnd
+      }
+      yLimit := y + REGION_SIZE
+      if yLimit > w.height {
+        yLimit = w.height
+      }
+      area := (xLimit - x) * (yLimit - y)
+
       biomeCount := [BIOMES]int{0}
-      treeHeap := make(LocMaxHeap, REGION_AREA)
-      rockHeap := make(LocMaxHeap, REGION_AREA)
-      plantHeap := make(LocMaxHeap, REGION_AREA)
+      treeHeap := make(LocMaxHeap, area)
+      rockHeap := make(LocMaxHeap, area)
+      plantHeap := make(LocMaxHeap, area)
+      soil := 0.0
+      land := 0
 
       i := 0
-      for ry := y; ry < y + REGION_SIZE; ry++ {
-        for rx := x; rx < x + REGION_SIZE; rx++ {
+      for ry := y; ry < yLimit; ry++ {
+        for rx := x; rx < xLimit; rx++ {
           tree := 0.0
           rock := 0.0
           plant := 0.0
           loc := w.Location(rx, ry)
           biome := loc.biome
           biomeCount[biome]++
+          if biome != OCEAN {
+            soil += loc.soil
+            land++
+          }
+          // Trees prefer deep soil while rocks are exposed where it is thin.
           if !loc.isWall {
-            if biome != OCEAN && biome != BEACH && !loc.isRiver {
-              tree = w.Tree(rx, ry)
+            if biome != OCEAN && biome != BEACH && !loc.isRiver &&
+               loc.soil > NO_SOIL {
+              tree = w.Tree(rx, ry) + SOIL_BIAS * loc.soil
             }
             if !loc.isRiverBank {
               plant = w.Plant(rx, ry)
             }
-            rock = w.Rock(rx, ry)
+            rock = w.Rock(rx, ry) - SOIL_BIAS * loc.soil
           }
           treeHeap[i] = &LocVal{ i, rx, ry, tree }
           rockHeap[i] = &LocVal{ i, rx, ry, rock }
@@ -567,12 +605,26 @@ func (w World) AnalyseRegions(xBegin, xEnd int, c chan int) {
         }
       }
       region.biome = uint8(maxBiome)
-
-      for i := 0; i < TREE_DENSITY[maxBiome]; i++ {
+      if w.placement != REGION_PLACEMENT {
+        continue
+      }
+      if land != 0 {
+        soil /= float64(land)
+      }
+      // Partial regions get a share of the features in proportion to their
+      // area.
+      numTrees := soilDensity(TREE_DENSITY[maxBiome] * area / REGION_AREA,
+                              soil, 0.5)
+      numRocks := soilDensity(ROCK_DENSITY[maxBiome] * area / REGION_AREA,
+                              soil, -0.5)
+      numPlants := soilDensity(PLANT_DENSITY[maxBiome] * area / REGION_AREA,
+                               soil, 0.25)
+
+      for i := 0; i < numTrees && treeHeap.Len() != 0; i++ {
         locVal := heap.Pop(&treeHeap).(*LocVal)
         w.addFeature(locVal.x, locVal.y, TREE_FEATURE);
       }
-      for i := 0; i < ROCK_DENSITY[maxBiome]; {
+      for i := 0; i < numRocks && rockHeap.Len() != 0; {
         locVal := heap.Pop(&rockHeap).(*LocVal)
         if w.Location(locVal.x, locVal.y).hasFeature(TREE_FEATURE) {
           if rockHeap.Len() == 0 {
@@ -583,7 +635,7 @@ func (w World) AnalyseRegions(xBegin, xEnd int, c chan int) {
         w.addFeature(locVal.x, locVal.y, ROCK_FEATURE);
         i++
       }
-      for i := 0; i < PLANT_DENSITY[maxBiome]; i++ {
+      for i := 0; i < numPlants && plantHeap.Len() != 0; i++ {
         locVal := heap.Pop(&plantHeap).(*LocVal)
         if w.Location(locVal.x, locVal.y).hasFeature(TREE_FEATURE) ||
            w.Location(locVal.x, locVal.y).hasFeature(ROCK_FEATURE) {
@@ -604,14 +656,18 @@ func (w World) Smooth() {
   height := w.height
   width := w.width
   count := 0
-  for y := 2; y < height; y++ {
+  for y := 0; y < height; y++ {
     for x := 0; x < width; x++ {
       centre := w.Location(x, y)
-      north := w.Location(x, y - 1)
+      north := w.WrappedLocation(x, y - 1)
+      beyond := w.WrappedLocation(x, y - 2)
+      if north == nil || beyond == nil {
+        continue
+      }
       if north.terrace > centre.terrace {
-        if w.Terrace(x, y - 2) != north.terrace {
-          w.SetTerrace(x, y - 2, north.terrace)
-          w.SetHeight(x, y - 2, north.height)
+        if beyond.terrace != north.terrace {
+          beyond.terrace = north.terrace
+          beyond.height = north.height
           count++
         }
       }
@@ -632,8 +688,12 @@ func (w World) FindNeighbours() {
         continue
       }
 
-      if y - 1 >= 0 {
-        north := w.Location(x, y - 1)
+      north := w.WrappedLocation(x, y - 1)
+      south := w.WrappedLocation(x, y + 1)
+      east := w.WrappedLocation(x + 1, y)
+      west := w.WrappedLocation(x - 1, y)
+
+      if north != nil {
         centre.addNeighbour(north)
         if north.terrace < centre.terrace {
           centre.addFeature(HORIZONTAL_SHADOW_FEATURE)
@@ -642,45 +702,38 @@ func (w World) FindNeighbours() {
         }
       }
 
-      if y + 1 < height {
-        south := w.Location(x, y + 1)
+      if south != nil {
         centre.addNeighbour(south)
       }
 
-      if x + 1 < width {
-        east := w.Location(x + 1, y)
+      if east != nil {
         centre.addNeighbour(east)
         if east.height >= centre.height && east.terrace > centre.terrace {
           centre.addFeature(LEFT_SHADOW_FEATURE)
         }
       }
 
-      if x - 1 >= 0 {
-        west := w.Location(x - 1, y)
+      if west != nil {
         centre.addNeighbour(west)
         if west.terrace > centre.terrace {
           centre.addFeature(RIGHT_SHADOW_FEATURE)
         }
       }
 
-      if x + 1 < width && y - 1 >= 0 {
-        topRight := w.Location(x + 1, y - 1)
-        top := w.Location(x, y - 1)
-        right := w.Location(x + 1, y)
+      if topRight := w.WrappedLocation(x + 1, y - 1);
+         topRight != nil && north != nil && east != nil {
         if topRight.terrace > centre.terrace &&
-           top.terrace == centre.terrace &&
-           right.terrace == centre.terrace {
+           north.terrace == centre.terrace &&
+           east.terrace == centre.terrace {
           centre.addFeature(BOTTOM_LEFT_SHADOW_FEATURE)
         }
       }
 
-      if x - 1 >= 0 && y - 1 >= 0 {
-        topLeft := w.Location(x - 1, y - 1)
-        top := w.Location(x, y - 1)
-        left := w.Location(x - 1, y)
+      if topLeft := w.WrappedLocation(x - 1, y - 1);
+         topLeft != nil && north != nil && west != nil {
         if topLeft.terrace > centre.terrace &&
-           top.terrace == centre.terrace &&
-           left.terrace == centre.terrace {
+           north.terrace == centre.terrace &&
+           west.terrace == centre.terrace {
           centre.addFeature(BOTTOM_RIGHT_SHADOW_FEATURE)
         }
       }
@@ -695,7 +748,7 @@ func (w World) FindHighest(xBegin, xEnd int, highest **Location, c chan int) {
     for x := xBegin; x < xEnd; x++ {
       loc := w.Location(x, y)
       if loc.hasFeature(TREE_FEATURE) ||
-         loc.hasFeature(ROCK_FEATURE) ||
+         loc.hasFeature(ROCK_FEATURE) || loc.coveredBy != nil ||
          loc.isWall || loc.isRiver || loc.isRiverBank {
         continue
       }
@@ -707,15 +760,15 @@ func (w World) FindHighest(xBegin, xEnd int, highest **Location, c chan int) {
   c <-1
 }
 
+// Calculate the height from noise and then raise, or lower, it depending on
+// the location's distance from the centre of the land. If a height map has
+// been imported, the result is blended into it.
 func (world World) CalcHeight(xBegin, xEnd int,
                               base, edgeUp, edgeDown, falloff float64,
-                              noise *opensimplex.Noise, c chan int) {
-  freq := world.hFreq
+                              shape ShapeFunc, heightmap *Heightmap,
+                              noise *Fractal, c chan int) {
   width := world.width
   height := world.height
-  n := *noise;
-  cx := float64(width / 2);
-  cy := float64(height / 2);
 
   for y := 0; y < height; y++ {
     yFloat := float64(y) / float64(height)
@@ -723,21 +776,15 @@ func (world World) CalcHeight(xBegin, xEnd int,
     for x := xBegin; x < xEnd; x++ {
       xFloat := float64(x) / float64(width)
       xBias :=  0.0
-      h := base +
-           0.75 * n.Eval2(freq * xFloat, freq * yFloat) +
-           0.50 * n.Eval2(2 * freq * xFloat, 2 * freq * yFloat) +
-           0.25 * n.Eval2(4 * freq * xFloat, 4 * freq * yFloat) +
-           0.125 * n.Eval2(8 * freq * xFloat, 8 * freq * yFloat) +
-	   xBias + yBias
-
-      nx := (cx - float64(x)) / cx
-      ny := (cy - float64(y)) / cy
-      distance := float64(2*math.Max(math.Abs(nx), math.Abs(ny)))
-      edgeUp := 0.07
-      edgeDown := 0.5
-      falloff := 1.5
+      h := base + noise.Eval(xFloat, yFloat) + xBias + yBias
+
+      distance := shape(x, y)
       h += edgeUp - edgeDown * math.Pow(distance, falloff)
 
+      if heightmap != nil {
+        h = heightmap.Blend(x, y, h)
+      }
+
       if h > HIGHLANDS {
         world.SetTerrace(x, y, 4)
       } else if h > MIDLANDS {
@@ -755,97 +802,70 @@ func (world World) CalcHeight(xBegin, xEnd int,
   c <- 1
 }
 
-func (w World) AddMoisture() {
-  count := 0
-  for len(w.clouds) != 0 {
-    cloud := w.clouds[0]
-    if cloud.update() {
-      w.clouds = w.clouds[1:]
-    }
-    count++
-  }
-  fmt.Println("updated", count, "clouds")
+// Blow clouds across the world, from the windward edge, so that they rain
+// onto the land they pass over. If given, onStep is called after every step
+// of the simulation.
+func (w *World) AddMoisture(water float64, maxClouds int,
+                            merge, verbose bool, onStep func(step int)) {
+  sim := CreateMoistureSim(w, water, maxClouds, merge, verbose)
+  sim.onStep = onStep
+  sim.Run()
 }
 
 func (w World) CalcBiome(xBegin, xEnd int, c chan int) {
   height := w.height
 
-  for x := xBegin; x < xEnd; x++ {
-    w.SetBiome(x, 0, biome(w.Height(x, 0), w.Moisture(x, 0)))
-  }
-
-  for y := 1; y < height; y++ {
+  for y := 0; y < height; y++ {
     for x := xBegin; x < xEnd; x++ {
-      if w.Terrace(x, y - 1) > w.Terrace(x, y) {
-        w.Location(x, y - 1).isWall = true;
+      // Where the world wraps vertically, the last row is above the first.
+      if above := w.WrappedLocation(x, y - 1);
+         above != nil && above.terrace > w.Terrace(x, y) {
+        above.isWall = true;
       }
-      w.SetBiome(x, y, biome(w.Height(x, y), w.Moisture(x, y)))
+      w.SetBiome(x, y, biome(w.Height(x, y), w.Moisture(x, y),
+                             w.Temperature(x, y), w.Soil(x, y)))
     }
   }
   c <-1 
 }
 
-func (w World) CalcTrees(xBegin, xEnd int,
-                         noise *opensimplex.Noise, c chan int) {
-  freq := w.tFreq
+func (w World) CalcTrees(xBegin, xEnd int, noise *Fractal, c chan int) {
   width := w.width
   height := w.height
-	n := *noise
 
   for y := 0; y < height; y++ {
     for x := xBegin; x < xEnd; x++ {
       xFloat := float64(x) / float64(width)
       yFloat := float64(y) / float64(height)
-      f := 1 * n.Eval2(freq * xFloat, freq * yFloat) +
-             0.50 * n.Eval2(2 * freq * xFloat, 2 * freq * yFloat) +
-             0.25 * n.Eval2(4 * freq * xFloat, 4 * freq * yFloat) +
-             0.125 * n.Eval2(8 * freq * xFloat, 8 * freq * yFloat)
-
-      w.SetTree(x, y, f)
+      w.SetTree(x, y, noise.Eval(xFloat, yFloat))
     }
   }
   c <- 1
 }
 
-func (w World) CalcPlants(xBegin, xEnd int,
-                          noise *opensimplex.Noise, c chan int) {
-  freq := w.pFreq
+func (w World) CalcPlants(xBegin, xEnd int, noise *Fractal, c chan int) {
   width := w.width
   height := w.height
-	n := *noise
 
   for y := 0; y < height; y++ {
     for x := xBegin; x < xEnd; x++ {
       xFloat := float64(x) / float64(width)
       yFloat := float64(y) / float64(height)
-      f := 1 * n.Eval2(freq * xFloat, freq * yFloat) +
-             0.50 * n.Eval2(2 * freq * xFloat, 2 * freq * yFloat) +
-             0.25 * n.Eval2(4 * freq * xFloat, 4 * freq * yFloat) +
-             0.125 * n.Eval2(8 * freq * xFloat, 8 * freq * yFloat)
-
-      w.SetPlant(x, y, f)
+      w.SetPlant(x, y, noise.Eval(xFloat, yFloat))
     }
   }
   c <- 1
 }
 
-func (w World) CalcRock(xBegin, xEnd int,
-                        noise *opensimplex.Noise, c chan int) {
-  freq := w.rFreq
+func (w World) CalcRock(xBegin, xEnd int, noise *Fractal, c chan int) {
   width := w.width
   height := w.height
-	n := *noise
 
   for y := 0; y < height; y++ {
     for x := xBegin; x < xEnd; x++ {
       xFloat := float64(x) / float64(width)
       yFloat := float64(y) / float64(height)
-      r := 1 * n.Eval2(freq * xFloat, freq * yFloat) +
-             0.50 * n.Eval2(2 * freq * xFloat, 2 * freq * yFloat) +
-             0.25 * n.Eval2(4 * freq * xFloat, 4 * freq * yFloat) +
-             0.125 * n.Eval2(8 * freq * xFloat, 8 * freq * yFloat)
-
-      w.SetRock(x, y, r)
+      w.SetRock(x, y, noise.Eval(xFloat, yFloat))
     }
   }
   c <- 1
@@ -870,7 +890,9 @@ func (w *World) FindRuntimePath(start *Location) {
   }
 }
 
-func (w *World) GeneratePath(start, goal *Location) bool {
+// Find the cheapest path from start to goal and mark it with path features,
+// or return ErrNoPath if the goal can't be reached.
+func (w *World) GeneratePath(start, goal *Location) error {
   graph := CreateGraph(w)
   startNode := graph.getNode(start)
   goalNode := graph.getNode(goal)
@@ -906,97 +928,192 @@ func (w *World) GeneratePath(start, goal *Location) bool {
     sort.Sort(NodeQueue(frontier))
   }
 
-  if found {
-    fmt.Println("found a path")
-    next := came_from[goalNode]
+  if !found {
+    return newError(ErrNoPath, fmt.Sprintf("from %d,%d to %d,%d", start.x,
+                                           start.y, goal.x, goal.y), nil)
+  }
+  next := came_from[goalNode]
 
-    for ; next != startNode; {
-      next = came_from[next]
-      next.loc.addFeature(PATH_FEATURE)
-    }
-  } else {
-    fmt.Println("failed to find a path")
+  for ; next != startNode; {
+    next = came_from[next]
+    next.loc.addFeature(PATH_FEATURE)
   }
-  return found
+  return nil
 }
 
-func GenerateMap(hFreq, heightBaseline, edgeUp, edgeDown, falloff,
-                 water, saturate,
-                 tFreq, pFreq, rFreq float64,
-                 width, height int, windDir uint, numCPUs int) {
-  rand.Seed(time.Now().UTC().UnixNano())
-  hSeed := rand.Int63()
-  tSeed := rand.Int63()
-  pSeed := rand.Int63()
-  rSeed := rand.Int63()
-  fmt.Println("height seed:", hSeed)
-  fmt.Println("tree seed:", tSeed)
-  fmt.Println("plant seed:", pSeed)
-  fmt.Println("rock seed:", rSeed)
-  hNoise := opensimplex.New(hSeed)
-  tNoise := opensimplex.New(tSeed)
-  pNoise := opensimplex.New(pSeed)
-  rNoise := opensimplex.New(rSeed)
-
-  world := CreateWorld(width, height, windDir, hFreq, tFreq, pFreq, rFreq, water)
-  start := time.Now()
+// Generate a world from the config, draw it and export it. Any error is one
+// of the kinds in errors.go, so a caller can tell a bad config from a missing
+// resource or a failure to write the results. Everything a run needs comes
+// from the config, so separate runs can be generated at the same time.
+func GenerateMap(config *Config) error {
+  if config.Width < 1 || config.Height < 1 || config.Threads < 1 {
+    return newError(ErrBadParameter, "the width, height and number of " +
+                    "threads all need to be at least one", nil)
+  }
+  if config.Scale < 1 || config.OverworldScale < 1 || config.PosterScale < 1 {
+    return newError(ErrBadParameter, "the map scales need to be at least one",
+                    nil)
+  }
+  if config.Band < 1 || config.Split < 0 {
+    return newError(ErrBadParameter, "the band needs to be at least one row " +
+                    "and the split can't be negative", nil)
+  }
+  if config.Hillshade < 0 || config.Occlusion < 0 ||
+     config.SunElevation <= 0 || config.SunElevation > 90 {
+    return newError(ErrBadParameter, "the lighting strengths can't be " +
+                    "negative and the sun has to be above the horizon", nil)
+  }
+  if config.HeightmapMix < 0 || config.HeightmapMix > 1 {
+    return newError(ErrBadParameter, "the heightmap mix is a proportion, " +
+                    "between zero and one", nil)
+  }
+  if config.TimelapseClouds < 0 ||
+     (config.TimelapseClouds > 0 && !config.Timelapse) {
+    return newError(ErrBadParameter, "the timelapse cloud steps can't be " +
+                    "negative and are only used with -timelapse", nil)
+  }
+  width := config.Width
+  height := config.Height
+  numCPUs := config.Threads
+  wrapX := config.WrapX
+  wrapY := config.WrapY
+  hSeed := config.Seeds.Height
+  tSeed := config.Seeds.Tree
+  pSeed := config.Seeds.Plant
+  rSeed := config.Seeds.Rock
+  teSeed := config.Seeds.Temperature
+  sSeed := config.Seeds.Soil
+  fmt.Println("height seed:", hexSeed(hSeed))
+  fmt.Println("tree seed:", hexSeed(tSeed))
+  fmt.Println("plant seed:", hexSeed(pSeed))
+  fmt.Println("rock seed:", hexSeed(rSeed))
+  fmt.Println("temperature seed:", hexSeed(teSeed))
+  fmt.Println("soil seed:", hexSeed(sSeed))
+  output, err := CreateOutput(config, time.Now())
+  if err != nil {
+    return err
+  }
+  hNoise, err := CreateLayerNoise(config.HeightNoise, hSeed, wrapX, wrapY)
+  if err != nil {
+    return fmt.Errorf("height noise: %w", err)
+  }
+  tNoise, err := CreateLayerNoise(config.TreeNoise, tSeed, wrapX, wrapY)
+  if err != nil {
+    return fmt.Errorf("tree noise: %w", err)
+  }
+  pNoise, err := CreateLayerNoise(config.PlantNoise, pSeed, wrapX, wrapY)
+  if err != nil {
+    return fmt.Errorf("plant noise: %w", err)
+  }
+  rNoise, err := CreateLayerNoise(config.RockNoise, rSeed, wrapX, wrapY)
+  if err != nil {
+    return fmt.Errorf("rock noise: %w", err)
+  }
+  teNoise, err := CreateLayerNoise(DefaultNoise(config.TempFreq),
+                                   teSeed, wrapX, wrapY)
+  if err != nil {
+    return fmt.Errorf("temperature noise: %w", err)
+  }
+  sNoise, err := CreateLayerNoise(DefaultNoise(config.SFreq), sSeed,
+                                  wrapX, wrapY)
+  if err != nil {
+    return fmt.Errorf("soil noise: %w", err)
+  }
+
+  // Load every sheet before generating anything, so that a bad resource is
+  // found before any of the outputs are written.
+  resources, err := CreateResources(config.Resources)
+  if err != nil {
+    return err
+  }
+  tileset, err := LoadTileset(resources, config.Tileset)
+  if err != nil {
+    return err
+  }
+  largeFeatures, err := LoadManifest(tileset.resources, tileset.LargeFeatures)
+  if err != nil {
+    return err
+  }
+  maps, err := CreateSeasonMaps(width, height, hSeed, tileset, config)
+  if err != nil {
+    return err
+  }
 
-  numThreads := 4 * numCPUs
-  c := make(chan int, numThreads)
-  for i := 0; i < numCPUs; i++ {
-    xBegin := i * width / numCPUs
-    xEnd := (i + 1) * width / numCPUs
-    go world.CalcHeight(xBegin, xEnd, heightBaseline, edgeUp, edgeDown,
-                        falloff, &hNoise, c)
-    go world.CalcTrees(xBegin, xEnd, &tNoise, c)
-    go world.CalcPlants(xBegin, xEnd, &pNoise, c)
-    go world.CalcRock(xBegin, xEnd, &rNoise, c)
+  world := CreateWorld(config)
+  world.treeRange = tNoise.Amplitude()
+  world.plantRange = pNoise.Amplitude()
+  world.rockRange = rNoise.Amplitude()
+  shape, err := CreateShape(config, hSeed)
+  if err != nil {
+    return err
+  }
+  var heightmap *Heightmap
+  if config.Heightmap != "" {
+    heightmap, err = LoadHeightmap(config.Heightmap, width, height,
+                                   config.HeightmapMin, config.HeightmapMax,
+                                   config.HeightmapMix)
+    if err != nil {
+      return err
+    }
   }
-  for i := 0; i < numThreads; i++ {
-    <-c
+  var timelapse *Timelapse
+  if config.Timelapse {
+    timelapse = CreateTimelapse(config.OverworldScale, config.TimelapseClouds)
   }
+  start := time.Now()
 
-  world.AddMoisture()
+  queue := CreateWorkQueue(width, numCPUs)
+  queue.Run(func(xBegin, xEnd int, c chan int) {
+              world.CalcHeight(xBegin, xEnd, config.HeightBaseline,
+                               config.EdgeUp, config.EdgeDown, config.Falloff,
+                               shape, heightmap, hNoise, c)
+            },
+            func(xBegin, xEnd int, c chan int) {
+              world.CalcTrees(xBegin, xEnd, tNoise, c)
+            },
+            func(xBegin, xEnd int, c chan int) {
+              world.CalcPlants(xBegin, xEnd, pNoise, c)
+            },
+            func(xBegin, xEnd int, c chan int) {
+              world.CalcRock(xBegin, xEnd, rNoise, c)
+            })
+  timelapse.Snapshot(world, heightColour)
+  timelapse.Snapshot(world, terraceColour)
+
+  world.AddMoisture(config.Water, config.MaxClouds, config.MergeClouds,
+                    config.CloudStats, func(step int) {
+                      timelapse.CloudStep(world, step)
+                    })
   world.Smooth()
+  timelapse.Snapshot(world, moistureColour)
+
+  // Temperature and soil depth depend on the final, smoothed, heights and
+  // are needed before the biomes can be chosen.
+  queue.Run(func(xBegin, xEnd int, c chan int) {
+              world.CalcTemperature(xBegin, xEnd, teNoise, c)
+            },
+            func(xBegin, xEnd int, c chan int) {
+              world.CalcSoil(xBegin, xEnd, sNoise, c)
+            })
 
   // We've calculate the heights, so now do the second pass and add shadow
   // features.
   // Calculate the biome once all attributes have been calculated.
-  numThreads = 1 * numCPUs
-  c = make(chan int, numThreads)
-  for i := 0; i < numThreads; i++ {
-    xBegin := i * width / numCPUs
-    xEnd := (i + 1) * width / numCPUs
-    go world.CalcBiome(xBegin, xEnd, c)
-  }
-  for i := 0; i < numThreads; i++ {
-    <-c
-  }
+  queue.Run(world.CalcBiome)
+  timelapse.Snapshot(world, overworldColour)
 
   world.FindNeighbours()
-  world.AddRivers(saturate)
-
-  numThreads = numCPUs * 2
-  c = make(chan int, numThreads)
-  for i := 0; i < numCPUs; i++ {
-    xBegin := i * width / numCPUs
-    xEnd := (i + 1) * width / numCPUs
-    go world.AddRiverBanks(xBegin, xEnd, c)
-    go world.AddGroundFeature(xBegin, xEnd, c)
-  }
-  for i := 0; i < numThreads; i++ {
-    <-c
-  }
-
-  c = make(chan int, numCPUs)
-  for i := 0; i < numCPUs; i++ {
-    xBegin := i * width / numCPUs
-    xEnd := (i + 1) * width / numCPUs
-    go world.AnalyseRegions(xBegin, xEnd, c)
-  }
-  for i := 0; i < numCPUs; i++ {
-    <-c
+  world.AddRivers(config.Saturate)
+  timelapse.Snapshot(world, stageColour)
+
+  queue.Run(world.AddRiverBanks, world.AddGroundFeature)
+  timelapse.Snapshot(world, stageColour)
+  queue.Run(world.AnalyseRegions)
+  if config.Placement == POISSON_PLACEMENT {
+    world.PlaceFeatures(config.Spacing, tSeed)
   }
+  world.AddLargeFeatures(config.LargeFeatures, rSeed, largeFeatures)
+  timelapse.Snapshot(world, stageColour)
 
   for y := 0; y < world.height; y++ {
     for x := 0; x < world.width; x++ {
@@ -1041,61 +1158,18 @@ func GenerateMap(hFreq, heightBaseline, edgeUp, edgeDown, falloff,
 
   fmt.Println("Duration: ", time.Now().Sub(start));
 
-  DrawMap(world, hSeed, tSeed, rSeed, numCPUs)
-  ExportJSON(world)
-}
-
-func main() {
-  // 64 x 48 = 1024 x 768
-  // 128 x 96 = 2048 x 1546
-  // 192 x 144 = 3072 x 2304
-  // 192 x 192 = 3072 x 3072
-  width := flag.Int("width", 192, "map width")
-  height := flag.Int("height", 144, "map height")
-  hFreq := flag.Float64("hFreq", 1.6, "height noise frequency")
-  bias := flag.Float64("bias", 0.0, "height bias")
-  edgeUp := flag.Float64("raise-edge", 0.05, "raise edges")
-  edgeDown := flag.Float64("lower-edge", 0.4, "lower edges")
-  falloff := flag.Float64("falloff", 5.0, "falloff rate")
-
-  water := flag.Float64("water", 100, "water")
-  saturate := flag.Float64("saturate", 30, "water saturation level")
-  direction := flag.String("wind", "n", "wind direction")
-  tFreq := flag.Float64("tFreq", 200, "tree noise frequency")
-  pFreq := flag.Float64("pFreq", 200, "plant noise frequency")
-  rFreq := flag.Float64("rFreq", 200, "rock noise frequency")
-  threads := flag.Int("threads", 1, "number of cores to use")
-
-  flag.Parse()
-
-  if *width % (REGION_SIZE * *threads) != 0 {
-    fmt.Println("With a region size of", REGION_SIZE,
-                ", width needs to be a factor of", *threads * REGION_SIZE,
-                "to use", *threads, "threads.")
-    return
-  } else if *height % (REGION_SIZE * *threads) != 0 {
-    fmt.Println("With a region size of", REGION_SIZE,
-                ", height needs to be a factor of", *threads * REGION_SIZE,
-                "to use", *threads, "threads.")
-    return
+  if err := timelapse.Save(output.Path("-timelapse.gif")); err != nil {
+    return err
   }
 
-  var windDir uint = NORTH
-  if *direction == "e" {
-    windDir = EAST
-  } else if *direction == "s" {
-    windDir = SOUTH
-  } else if *direction == "w" {
-    windDir = WEST
-  } else if *direction != "n" {
-    fmt.Println("Invalid wind direction, choose: n,e,s,w");
-    return
+  if err := DrawMap(world, maps, config, output); err != nil {
+    return err
   }
-
-  fmt.Println("width, height, threads")
-  fmt.Println(*width, ",", *height, ",", *threads)
-  GenerateMap(*hFreq, *bias, *edgeUp, *edgeDown, *falloff,
-              *water, *saturate,
-              *tFreq, *pFreq, *rFreq,
-              *width, *height, windDir, *threads)
+  if err := ExportJSON(world, output); err != nil {
+    return err
+  }
+  if config.Layers {
+    return ExportLayers(world, output)
+  }
+  return nil
 }
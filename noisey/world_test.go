@@ -0,0 +1,169 @@
+package noisey
+
+import (
+  "bytes"
+  "errors"
+  "image"
+  "math"
+  "os"
+  "path/filepath"
+  "testing"
+)
+
+// The terrace follows the height thresholds, where a height on a threshold
+// belongs to the terrace below it.
+func TestCalcHeightTerraces(t *testing.T) {
+  tests := []struct {
+    height float64
+    terrace uint8
+  } {
+    { WATER_LEVEL, 0 },
+    { BEACH_LEVEL, 0 },
+    { BEACH_LEVEL + 0.01, 1 },
+    { LOWLANDS, 1 },
+    { LOWLANDS + 0.01, 2 },
+    { MIDLANDS, 2 },
+    { MIDLANDS + 0.01, 3 },
+    { HIGHLANDS, 3 },
+    { HIGHLANDS + 0.01, 4 },
+  }
+  flat := func(x, y int) float64 { return 0 }
+  noise := fractal(t, constNoise(0), "fbm", 0.75)
+  for _, test := range tests {
+    w := CreateWorld(&Config{ Width: 1, Height: 1 })
+    c := make(chan int, 1)
+    w.CalcHeight(0, 1, test.height, 0, 0, 1, flat, nil, noise, c)
+    if got := w.Height(0, 0); got != test.height {
+      t.Errorf("height %v: got a height of %v", test.height, got)
+    }
+    if got := w.Terrace(0, 0); got != test.terrace {
+      t.Errorf("height %v: got terrace %d, want %d", test.height, got,
+               test.terrace)
+    }
+  }
+}
+
+// Two runs from the same config, generated at the same time, produce the
+// same world.
+func TestGenerateMapConcurrently(t *testing.T) {
+  dirs := []string{ t.TempDir(), t.TempDir() }
+  errs := make(chan error, len(dirs))
+  for _, dir := range dirs {
+    config, err := ParseConfig([]string{ "-width", "32", "-height", "24",
+                                         "-hSeed", "2a", "-tSeed", "2b",
+                                         "-pSeed", "2c", "-rSeed", "2d",
+                                         "-teSeed", "2e", "-sSeed", "2f",
+                                         "-name", "run", "-outdir", dir })
+    if err != nil {
+      t.Fatal(err)
+    }
+    go func() {
+      errs <- GenerateMap(config)
+    }()
+  }
+  for range dirs {
+    if err := <-errs; err != nil {
+      t.Fatal(err)
+    }
+  }
+  var worlds [][]byte
+  for _, dir := range dirs {
+    world, err := os.ReadFile(filepath.Join(dir, "run-world.json"))
+    if err != nil {
+      t.Fatal(err)
+    }
+    worlds = append(worlds, world)
+  }
+  if !bytes.Equal(worlds[0], worlds[1]) {
+    t.Error("the worlds differ")
+  }
+}
+
+// A river bank without a water edge tile is reported, rather than stopping
+// the whole program.
+func TestDrawAreaBadRiverBank(t *testing.T) {
+  config, err := ParseConfig([]string{ "-width", "4", "-height", "4" })
+  if err != nil {
+    t.Fatal(err)
+  }
+  resources, err := CreateResources("")
+  if err != nil {
+    t.Fatal(err)
+  }
+  tileset, err := LoadTileset(resources, config.Tileset)
+  if err != nil {
+    t.Fatal(err)
+  }
+  maps, err := CreateSeasonMaps(4, 4, 1, tileset, config)
+  if err != nil {
+    t.Fatal(err)
+  }
+  w := CreateWorld(config)
+  loc := w.Location(1, 2)
+  loc.isRiverBank = true
+  loc.riverBank = BOTTOM_RIGHT_RIVER_FEATURE + 1
+  _, err = maps[0].render.DrawArea(w, image.Rect(0, 0, 4, 4), 2)
+  if !errors.Is(err, ErrBadResource) {
+    t.Errorf("got %v, want a bad resource", err)
+  }
+}
+
+// A drop between the last row and the first is a wall only where the world
+// wraps vertically.
+func TestCalcBiomeWrappedWall(t *testing.T) {
+  for _, wrapY := range []bool{ false, true } {
+    w := CreateWorld(&Config{ Width: 1, Height: 3, WrapY: wrapY })
+    for y, terrace := range []uint8{ 1, 1, 2 } {
+      w.SetTerrace(0, y, terrace)
+    }
+    c := make(chan int, 1)
+    w.CalcBiome(0, 1, c)
+    if got := w.Location(0, 2).isWall; got != wrapY {
+      t.Errorf("wrapY %v: got a wall of %v", wrapY, got)
+    }
+    if w.Location(0, 0).isWall || w.Location(0, 1).isWall {
+      t.Errorf("wrapY %v: got a wall above a level step", wrapY)
+    }
+  }
+}
+
+// The temperature noise, here a fake at either extreme of its range, adds up
+// to tempNoise degrees either way.
+func TestCalcTemperatureNoise(t *testing.T) {
+  config := &Config{ Width: 1, Height: 1, LatNorth: 50, LatSouth: 50,
+                     TempNoise: 4 }
+  temperature := func(noise Noise2D) float64 {
+    w := CreateWorld(config)
+    w.SetHeight(0, 0, WATER_LEVEL)
+    c := make(chan int, 1)
+    w.CalcTemperature(0, 1, fractal(t, noise, "fbm", 1), c)
+    return w.Temperature(0, 0)
+  }
+  base := temperature(constNoise(0))
+  if got := temperature(constNoise(1)) - base; math.Abs(got - 4) > 1e-12 {
+    t.Errorf("highest noise: got %v degrees warmer, want 4", got)
+  }
+  if got := temperature(constNoise(-1)) - base; math.Abs(got + 4) > 1e-12 {
+    t.Errorf("lowest noise: got %v degrees warmer, want -4", got)
+  }
+}
+
+// Parameters outside their ranges are rejected before anything is
+// generated.
+func TestGenerateMapBadParameters(t *testing.T) {
+  tests := [][]string {
+    { "-heightmap-mix", "-0.1" },
+    { "-heightmap-mix", "1.5" },
+    { "-timelapse-clouds", "5" },
+    { "-width", "0" },
+  }
+  for _, args := range tests {
+    config, err := ParseConfig(append(args, "-outdir", t.TempDir()))
+    if err != nil {
+      t.Fatal(err)
+    }
+    if err := GenerateMap(config); !errors.Is(err, ErrBadParameter) {
+      t.Errorf("%v: got %v, want a bad parameter", args, err)
+    }
+  }
+}